@@ -0,0 +1,422 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// reachableFrom returns every room that can be reached from room by
+// following the given adjacency lists, including room itself.
+func reachableFrom(adjacency map[string][]string, room string) map[string]bool {
+	seen := map[string]bool{room: true}
+	queue := []string{room}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range adjacency[current] {
+			if !seen[neighbor] {
+				seen[neighbor] = true
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return seen
+}
+
+// findUnreachableRooms returns, in name order, the rooms that lie on no route
+// from the start room to the end room: those the start cannot reach and those
+// that cannot reach the end.
+func findUnreachableRooms(graph *Graph) []string {
+	reverse := make(map[string][]string, len(graph.Connections))
+	for room, neighbors := range graph.Connections {
+		for _, neighbor := range neighbors {
+			reverse[neighbor] = append(reverse[neighbor], room)
+		}
+	}
+	fromStart := reachableFrom(graph.Connections, graph.StartRoom)
+	toEnd := reachableFrom(reverse, graph.EndRoom)
+
+	var unreachable []string
+	for name := range graph.Rooms {
+		if !fromStart[name] || !toEnd[name] {
+			unreachable = append(unreachable, name)
+		}
+	}
+	sort.Strings(unreachable)
+	return unreachable
+}
+
+// maxDisjointPaths returns how many paths from start to end can run side by
+// side without sharing a room, which is the maximum flow of the graph.
+func maxDisjointPaths(graph *Graph) int {
+	network := newFlowNetwork(graph)
+	return network.maxFlow(network.out(graph.StartRoom), network.in(graph.EndRoom))
+}
+
+// lowerBoundTurns returns a number of turns no solution can beat, or 0 if
+// the end room cannot be reached. The first ant needs as many turns as the
+// shortest route has tunnels, and no turn can bring more ants into the end
+// room than the maximum flow of the map, with rooms passing as many ants as
+// they hold and tunnels as many as they are wide.
+func lowerBoundTurns(graph *Graph, ants int) int {
+	moves, ok := distancesFrom(graph, graph.StartRoom)[graph.EndRoom]
+	if !ok {
+		return 0
+	}
+	network := newCapacityNetwork(graph)
+	throughput := network.maxFlow(network.out(graph.StartRoom), network.in(graph.EndRoom))
+	return moves - 1 + (ants+throughput-1)/throughput
+}
+
+// queueLimited reports whether the ants are so many that even spread over
+// every disjoint path they would spend more turns queueing at the start than
+// travelling: each path would carry more ants than the longest of the chosen
+// paths has rooms. More paths through the same map cannot help then.
+func queueLimited(paths [][]string, disjoint, ants int) bool {
+	longest := 0
+	for _, path := range paths {
+		longest = max(longest, len(path))
+	}
+	return disjoint > 0 && ants > disjoint*longest
+}
+
+// criticalPath returns the number of moves along the longest of the paths:
+// the turns the pipeline takes to fill, before queueing adds any more.
+func criticalPath(paths [][]string) int {
+	longest := 0
+	for _, path := range paths {
+		longest = max(longest, len(path)-1)
+	}
+	return longest
+}
+
+// findSymmetricRooms returns groups of rooms on routes from start to end that
+// lead to exactly the same rooms, such as the two sides of a diamond. Rooms in
+// a group are interchangeable, so every path through one has an equivalent
+// path through each of the others, and the path search repeats its work for
+// each. It is a best-effort check: symmetry spread over longer branches goes
+// unnoticed. Groups come in name order.
+func findSymmetricRooms(graph *Graph) [][]string {
+	unreachable := make(map[string]bool)
+	for _, room := range findUnreachableRooms(graph) {
+		unreachable[room] = true
+	}
+	byNeighbors := make(map[string][]string)
+	for name, neighbors := range graph.Connections {
+		if graph.Unlimited(name) || unreachable[name] || len(neighbors) == 0 {
+			continue
+		}
+		sorted := slices.Clone(neighbors)
+		sort.Strings(sorted)
+		key := strings.Join(sorted, "\x00")
+		byNeighbors[key] = append(byNeighbors[key], name)
+	}
+
+	var groups [][]string
+	for _, group := range byNeighbors {
+		if len(group) > 1 {
+			sort.Strings(group)
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// distancesFrom returns the number of tunnels on the shortest route from room
+// to every room it can reach.
+func distancesFrom(graph *Graph, room string) map[string]int {
+	distance := map[string]int{room: 0}
+	queue := []string{room}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range graph.Connections[current] {
+			if _, ok := distance[neighbor]; !ok {
+				distance[neighbor] = distance[current] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return distance
+}
+
+// maxDiameterRooms is the largest connected part of the graph diameter
+// measures. Measuring it takes a search from every room in it, which grows
+// with the square of its size.
+const maxDiameterRooms = 2000
+
+// diameter returns the longest shortest route, in tunnels, between any two
+// rooms that are connected at all. It measures each connected part of the
+// graph on its own and reports false if one has more than maxDiameterRooms
+// rooms.
+func diameter(graph *Graph) (int, bool) {
+	adjacency := undirected(graph)
+	seen := make(map[string]bool, len(graph.Rooms))
+	longest := 0
+	for name := range graph.Rooms {
+		if seen[name] {
+			continue
+		}
+		component := reachableFrom(adjacency, name)
+		if len(component) > maxDiameterRooms {
+			return 0, false
+		}
+		for room := range component {
+			seen[room] = true
+			for _, distance := range distancesFrom(graph, room) {
+				longest = max(longest, distance)
+			}
+		}
+	}
+	return longest, true
+}
+
+// endpointCandidates returns, in name order, the rooms that could serve as a
+// missing start or end room: the dead ends, which have a single tunnel and
+// are not already the start or end.
+func endpointCandidates(graph *Graph) []string {
+	var candidates []string
+	for name := range graph.Rooms {
+		if graph.Degree(name) == 1 && !graph.Unlimited(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// findHubs returns, in name order, the rooms with more than threshold
+// tunnels. Such rooms are where paths are most likely to collide.
+func findHubs(graph *Graph, threshold int) []string {
+	var hubs []string
+	for name := range graph.Rooms {
+		if graph.Degree(name) > threshold {
+			hubs = append(hubs, name)
+		}
+	}
+	sort.Strings(hubs)
+	return hubs
+}
+
+// findArticulationPoints returns, in name order, the rooms whose removal
+// would cut the start room off from the end room. It runs Tarjan's lowlink
+// depth-first search from the start: a room is such a cut room when the end
+// lies below one of its children in the search tree and that child's subtree
+// has no tunnel leading back above the room. Which way the tunnels lead is
+// ignored. The search keeps its own stack rather than recursing, so the
+// graph may be as deep as memory allows.
+func findArticulationPoints(graph *Graph) []string {
+	adjacency := undirected(graph)
+	discovered := make(map[string]int)
+	low := make(map[string]int)
+	var points []string
+
+	// frame is a room in the search tree, the index of the next neighbor to
+	// explore from it, whether the end room was found in its subtree and
+	// whether it cuts the start off from the end.
+	type frame struct {
+		room       string
+		next       int
+		reachesEnd bool
+		cut        bool
+	}
+
+	var stack []frame
+	enter := func(room string) {
+		discovered[room] = len(discovered) + 1
+		low[room] = discovered[room]
+		stack = append(stack, frame{room: room, reachesEnd: room == graph.EndRoom})
+	}
+
+	enter(graph.StartRoom)
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(adjacency[top.room]) {
+			neighbor := adjacency[top.room][top.next]
+			top.next++
+			if _, seen := discovered[neighbor]; seen {
+				low[top.room] = min(low[top.room], discovered[neighbor])
+				continue
+			}
+			enter(neighbor)
+			continue
+		}
+
+		// The subtree of the room is done; report it to the parent.
+		child := *top
+		stack = stack[:len(stack)-1]
+		if child.cut && child.room != graph.StartRoom && child.room != graph.EndRoom {
+			points = append(points, child.room)
+		}
+		if len(stack) == 0 {
+			break
+		}
+		parent := &stack[len(stack)-1]
+		if child.reachesEnd {
+			parent.reachesEnd = true
+			parent.cut = parent.cut || low[child.room] >= discovered[parent.room]
+		}
+		low[parent.room] = min(low[parent.room], low[child.room])
+	}
+
+	sort.Strings(points)
+	return points
+}
+
+// undirected returns the tunnels of the graph as adjacency lists in which
+// one-way tunnels lead both ways too.
+func undirected(graph *Graph) map[string][]string {
+	adjacency := make(map[string][]string, len(graph.Connections))
+	for name, neighbors := range graph.Connections {
+		adjacency[name] = append(adjacency[name], neighbors...)
+		for _, neighbor := range neighbors {
+			if graph.OneWay(name, neighbor) {
+				adjacency[neighbor] = append(adjacency[neighbor], name)
+			}
+		}
+	}
+	return adjacency
+}
+
+// countComponents returns the number of connected components of the graph,
+// ignoring which way its tunnels lead.
+func countComponents(graph *Graph) int {
+	adjacency := undirected(graph)
+	seen := make(map[string]bool, len(graph.Rooms))
+	components := 0
+	for name := range graph.Rooms {
+		if seen[name] {
+			continue
+		}
+		components++
+		for room := range reachableFrom(adjacency, name) {
+			seen[room] = true
+		}
+	}
+	return components
+}
+
+// findBridges returns the tunnels whose removal would split a connected part
+// of the graph in two, as "a-b" with the names in order, sorted. It runs
+// Tarjan's lowlink depth-first search from every unvisited room: a tunnel to
+// a child in the search tree is a bridge when the child's subtree has no
+// other tunnel leading back to the room or above it. Which way the tunnels
+// lead is ignored. Like findArticulationPoints, the search keeps its own
+// stack.
+func findBridges(graph *Graph) []string {
+	adjacency := undirected(graph)
+	discovered := make(map[string]int)
+	low := make(map[string]int)
+	var bridges []string
+
+	// frame is a room in the search tree, the room it was reached from and
+	// the index of the next neighbor to explore from it.
+	type frame struct {
+		room, parent string
+		next         int
+	}
+
+	var stack []frame
+	enter := func(room, parent string) {
+		discovered[room] = len(discovered) + 1
+		low[room] = discovered[room]
+		stack = append(stack, frame{room: room, parent: parent})
+	}
+
+	names := make([]string, 0, len(graph.Rooms))
+	for name := range graph.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, seen := discovered[name]; seen {
+			continue
+		}
+		enter(name, "")
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(adjacency[top.room]) {
+				neighbor := adjacency[top.room][top.next]
+				top.next++
+				if neighbor == top.parent {
+					continue
+				}
+				if _, seen := discovered[neighbor]; seen {
+					low[top.room] = min(low[top.room], discovered[neighbor])
+					continue
+				}
+				enter(neighbor, top.room)
+				continue
+			}
+
+			child := *top
+			stack = stack[:len(stack)-1]
+			if child.parent == "" {
+				continue
+			}
+			low[child.parent] = min(low[child.parent], low[child.room])
+			if low[child.room] > discovered[child.parent] {
+				bridges = append(bridges, min(child.parent, child.room)+"-"+max(child.parent, child.room))
+			}
+		}
+	}
+
+	sort.Strings(bridges)
+	return bridges
+}
+
+// printStats writes a summary of the size and connectivity of the graph.
+func printStats(w io.Writer, graph *Graph) {
+	links, oneWay := 0, 0
+	for name, neighbors := range graph.Connections {
+		for _, neighbor := range neighbors {
+			if graph.OneWay(name, neighbor) {
+				oneWay++
+			}
+			if graph.lists(name, neighbor) {
+				links++
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Rooms: %d\n", len(graph.Rooms))
+	fmt.Fprintf(w, "Links: %d\n", links)
+	if oneWay > 0 {
+		fmt.Fprintf(w, "One-way links: %d\n", oneWay)
+	}
+	if len(graph.Rooms) > 0 {
+		fmt.Fprintf(w, "Average degree: %.2f\n", float64(2*links)/float64(len(graph.Rooms)))
+		// A hub has more than twice the average number of tunnels.
+		if hubs := findHubs(graph, 4*links/len(graph.Rooms)); len(hubs) > 0 {
+			fmt.Fprintf(w, "Hubs: %s\n", strings.Join(hubs, ", "))
+		}
+	}
+	if longest, ok := diameter(graph); ok {
+		fmt.Fprintf(w, "Diameter: %d\n", longest)
+	} else {
+		fmt.Fprintf(w, "Diameter: not measured, over %d connected rooms\n", maxDiameterRooms)
+	}
+	// Weights only rank paths, so the distance counts tunnels, not cost.
+	if distance, ok := distancesFrom(graph, graph.StartRoom)[graph.EndRoom]; ok {
+		fmt.Fprintf(w, "Start-end distance: %d\n", distance)
+	} else {
+		fmt.Fprintln(w, "Start-end distance: unreachable")
+	}
+	fmt.Fprintf(w, "Disjoint start-end paths: %d\n", maxDisjointPaths(graph))
+	if points := findArticulationPoints(graph); len(points) > 0 {
+		fmt.Fprintf(w, "Articulation points: %s\n", strings.Join(points, ", "))
+	} else {
+		fmt.Fprintln(w, "Articulation points: none")
+	}
+	// Each tunnel beyond those of a spanning forest closes one more cycle.
+	fmt.Fprintf(w, "Independent cycles: %d\n", links-len(graph.Rooms)+countComponents(graph))
+	if bridges := findBridges(graph); len(bridges) > 0 {
+		fmt.Fprintf(w, "Bridges: %s\n", strings.Join(bridges, ", "))
+	} else {
+		fmt.Fprintln(w, "Bridges: none")
+	}
+}
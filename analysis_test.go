@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// unreachableMap has an isolated room, z, and a one-way tunnel into a dead
+// end, d, which can be entered but never left for the end.
+const unreachableMap = `1
+##start
+s 0 0
+a 1 0
+d 1 1
+z 5 5
+##end
+e 2 0
+s-a
+a-e
+a->d
+`
+
+func TestFindUnreachableRooms(t *testing.T) {
+	graph := mustParse(t, unreachableMap, parseOptions{})
+	want := []string{"d", "z"}
+	if got := findUnreachableRooms(graph); !slices.Equal(got, want) {
+		t.Errorf("findUnreachableRooms = %v, want %v", got, want)
+	}
+
+	stdout, _ := solveText(t, unreachableMap, options{verbose: true})
+	if !strings.Contains(stdout, "Unreachable rooms: d, z\n") {
+		t.Errorf("-v output does not report the unreachable rooms:\n%s", stdout)
+	}
+	stdout, _ = solveText(t, unreachableMap, options{})
+	if strings.Contains(stdout, "Unreachable") {
+		t.Errorf("output without -v reports unreachable rooms:\n%s", stdout)
+	}
+}
+
+// funnelMap has two routes from the start that meet in c, the only way to
+// the end.
+const funnelMap = `3
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 0
+##end
+e 3 0
+s-a
+s-b
+a-c
+b-c
+c-e
+`
+
+func TestMaxDisjointPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  int
+	}{
+		{"funnel", mustParse(t, funnelMap, parseOptions{}), 1},
+		{"grid", mustParse(t, gridMap(5, 4, 1), parseOptions{}), 4},
+		{"example01", readExample(t, "example01.txt"), 3},
+		{"example05", readExample(t, "example05.txt"), 4},
+	}
+	for _, tt := range tests {
+		if got := maxDisjointPaths(tt.graph); got != tt.want {
+			t.Errorf("%s: maxDisjointPaths = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	var buf bytes.Buffer
+	printStats(&buf, mustParse(t, funnelMap, parseOptions{}))
+	want := `Rooms: 5
+Links: 5
+Average degree: 2.00
+Diameter: 3
+Start-end distance: 3
+Disjoint start-end paths: 1
+Articulation points: c
+Independent cycles: 1
+Bridges: c-e
+`
+	if buf.String() != want {
+		t.Errorf("printStats wrote:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestPrintStatsCountsTunnels(t *testing.T) {
+	// The direct route is two tunnels but costs more than the three-tunnel
+	// detour.
+	graph := mustParse(t, "1\n##start\ns 0 0\nm 1 0\na 0 1\nb 1 1\n##end\ne 2 0\ns-m:5\nm-e\ns-a\na-b\nb-e\n", parseOptions{})
+	if got := len(findShortestPath(graph)) - 1; got != 3 {
+		t.Fatalf("cheapest path has %d tunnels, want the 3-tunnel detour", got)
+	}
+	var buf bytes.Buffer
+	printStats(&buf, graph)
+	if !strings.Contains(buf.String(), "Start-end distance: 2\n") {
+		t.Errorf("printStats wrote:\n%s\nwant a start-end distance of 2", buf.String())
+	}
+}
+
+func TestFindBridges(t *testing.T) {
+	// Two triangles joined by the tunnel c-d, a dead end f off the second
+	// and a separate pair x-y.
+	const triangles = `1
+##start
+a 0 0
+b 1 0
+c 2 0
+d 3 0
+e 4 0
+##end
+f 5 0
+x 6 0
+y 7 0
+a-b
+b-c
+c-a
+c-d
+d->e
+e-f
+e-d2
+d2 3 1
+d2-d
+x-y
+`
+	tests := []struct {
+		name   string
+		graph  *Graph
+		want   []string
+		cycles int
+	}{
+		{"funnel", mustParse(t, funnelMap, parseOptions{}), []string{"c-e"}, 1},
+		{"triangles", mustParse(t, triangles, parseOptions{}), []string{"c-d", "e-f", "x-y"}, 2},
+		{"grid", mustParse(t, gridMap(3, 3, 1), parseOptions{}), nil, 8},
+		{"chain", mustParse(t, chainMap, parseOptions{}), []string{"e-m", "m-s"}, 0},
+	}
+	for _, tt := range tests {
+		if got := findBridges(tt.graph); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: bridges = %v, want %v", tt.name, got, tt.want)
+		}
+		var buf bytes.Buffer
+		printStats(&buf, tt.graph)
+		if !strings.Contains(buf.String(), fmt.Sprintf("Independent cycles: %d\n", tt.cycles)) {
+			t.Errorf("%s: stats:\n%s\nwant %d independent cycles", tt.name, buf.String(), tt.cycles)
+		}
+	}
+}
+
+// starMap has a hub, h, that every other room is linked to.
+const starMap = `1
+##start
+s 0 0
+a 1 1
+b 1 -1
+h 1 0
+##end
+e 2 0
+s-h
+a-h
+b-h
+h-e
+s-a
+`
+
+func TestDegree(t *testing.T) {
+	graph := mustParse(t, starMap, parseOptions{})
+	want := map[string]int{"s": 2, "a": 2, "b": 1, "h": 4, "e": 1, "missing": 0}
+	for room, degree := range want {
+		if got := graph.Degree(room); got != degree {
+			t.Errorf("Degree(%s) = %d, want %d", room, got, degree)
+		}
+	}
+
+	if got := findHubs(graph, 2); !slices.Equal(got, []string{"h"}) {
+		t.Errorf("findHubs(2) = %v, want [h]", got)
+	}
+	if got := findHubs(graph, 1); !slices.Equal(got, []string{"a", "h", "s"}) {
+		t.Errorf("findHubs(1) = %v, want [a h s]", got)
+	}
+	if got := findHubs(graph, 4); got != nil {
+		t.Errorf("findHubs(4) = %v, want none", got)
+	}
+}
+
+func TestFindArticulationPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  []string
+	}{
+		{"funnel", mustParse(t, funnelMap, parseOptions{}), []string{"c"}},
+		{"example00", readExample(t, "example00.txt"), []string{"2", "3"}},
+		// b cuts x off from the rest, but not the start from the end.
+		{"dead end", mustParse(t, "1\n##start\ns 0 0\na 1 0\nb 1 1\nx 2 2\n##end\ne 2 0\ns-a\na-e\ns-b\nb-e\nb-x\n", parseOptions{}), nil},
+		// The one-way tunnel still joins a to the end.
+		{"one-way", mustParse(t, "1\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\ns-b\nb-e\ne->a\n", parseOptions{}), nil},
+	}
+	for _, tt := range tests {
+		if got := findArticulationPoints(tt.graph); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: findArticulationPoints = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLongChainAnalysis(t *testing.T) {
+	// A recursive search would need far more than this much stack.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const rooms = 50000
+	graph := mustParse(t, longChainMap(rooms, 1), parseOptions{})
+
+	if points := findArticulationPoints(graph); len(points) != rooms-2 {
+		t.Errorf("found %d articulation points, want %d", len(points), rooms-2)
+	}
+	if bridges := findBridges(graph); len(bridges) != rooms-1 {
+		t.Errorf("found %d bridges, want %d", len(bridges), rooms-1)
+	}
+
+	var buf bytes.Buffer
+	printStats(&buf, graph)
+	for _, want := range []string{
+		fmt.Sprintf("Diameter: not measured, over %d connected rooms\n", maxDiameterRooms),
+		fmt.Sprintf("Start-end distance: %d\n", rooms-1),
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printStats wrote:\n%s\nwant a line %q", buf.String(), want)
+		}
+	}
+}
+
+func TestDiameterPerComponent(t *testing.T) {
+	// A chain just within the limit is measured alongside a separate pair.
+	graph := mustParse(t, longChainMap(maxDiameterRooms, 1)+"x 0 1\ny 1 1\nx-y\n", parseOptions{})
+	if got, ok := diameter(graph); !ok || got != maxDiameterRooms-1 {
+		t.Errorf("diameter = %d, %t, want %d, true", got, ok, maxDiameterRooms-1)
+	}
+}
+
+func TestBottleneckMessage(t *testing.T) {
+	const chain = "100\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n"
+	stdout, _ := solveText(t, chain, options{verbose: true})
+	if !strings.Contains(stdout, "bottleneck: 1 disjoint paths, 100 ants, queue-limited\n") {
+		t.Errorf("-v output lacks the bottleneck message:\n%s", stdout)
+	}
+	if stdout, _ := solveText(t, chain, options{}); strings.Contains(stdout, "bottleneck") {
+		t.Errorf("output without -v has the bottleneck message:\n%s", stdout)
+	}
+
+	// Three ants fit the path without queueing longer than they travel.
+	stdout, _ = solveText(t, strings.Replace(chain, "100", "3", 1), options{verbose: true})
+	if strings.Contains(stdout, "bottleneck") {
+		t.Errorf("three ants on a path of three rooms are reported as queue-limited:\n%s", stdout)
+	}
+
+	if !queueLimited([][]string{{"s", "a", "e"}}, 1, 4) || queueLimited([][]string{{"s", "a", "e"}}, 2, 4) || queueLimited(nil, 0, 4) {
+		t.Error("queueLimited disagrees with the ants per disjoint path")
+	}
+}
+
+func TestInferCandidates(t *testing.T) {
+	const linear = "2\nb 1 0\na 0 0\nc 2 0\nd 3 0\na-b\nb-c\nc-d\n"
+	_, _, err := parseMap(strings.NewReader(linear), parseOptions{infer: true})
+	if err == nil || !strings.HasSuffix(err.Error(), "; candidates: a, d") {
+		t.Errorf("-infer on a linear map: err = %v, want the endpoints a and d listed", err)
+	}
+	_, _, plain := parseMap(strings.NewReader(linear), parseOptions{})
+	if plain == nil || strings.Contains(plain.Error(), "candidates") {
+		t.Errorf("without -infer: err = %v", plain)
+	}
+
+	// With the start given, only the other dead end is offered.
+	_, _, err = parseMap(strings.NewReader(strings.Replace(linear, "a 0 0", "##start\na 0 0", 1)), parseOptions{infer: true})
+	if err == nil || !strings.HasSuffix(err.Error(), "; candidates: d") {
+		t.Errorf("-infer with a start room: err = %v", err)
+	}
+
+	_, _, err = parseMap(strings.NewReader("2\na 0 0\nb 1 0\nc 2 0\na-b\nb-c\nc-a\n"), parseOptions{infer: true})
+	if err == nil || !strings.HasSuffix(err.Error(), "; no room has a single tunnel") {
+		t.Errorf("-infer on a cycle: err = %v", err)
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	// Two paths of two and three tunnels.
+	const twoPaths = "5\n##start\ns 0 0\na 1 0\nb 1 1\nc 2 1\n##end\ne 3 0\ns-a\na-e\ns-b\nb-c\nc-e\n"
+	graph := mustParse(t, twoPaths, parseOptions{})
+	paths, err := DFSSolver{}.ChoosePaths(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || criticalPath(paths) != 3 {
+		t.Errorf("critical path of %v = %d, want 3 along two paths", paths, criticalPath(paths))
+	}
+	if criticalPath(nil) != 0 {
+		t.Errorf("critical path of no paths = %d", criticalPath(nil))
+	}
+
+	stdout, results := solveText(t, twoPaths, options{noEcho: true, verbose: true})
+	turns := strings.Count(results, "\n")
+	if want := fmt.Sprintf("Critical path: 3 moves of %d turns\n", turns); turns <= 3 || !strings.Contains(stdout, want) {
+		t.Errorf("-v output:\n%s\nwant %q", stdout, want)
+	}
+	if stdout, _ := solveText(t, twoPaths, options{}); strings.Contains(stdout, "Critical path") {
+		t.Errorf("output without -v reports the critical path:\n%s", stdout)
+	}
+}
+
+func TestLowerBoundTurns(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  int
+	}{
+		{"example00", readExample(t, "example00.txt"), 6},
+		{"funnel", mustParse(t, funnelMap, parseOptions{}), 5},
+		{"shared room", mustParse(t, sharedRoomMap, parseOptions{}), 7},
+		{"wide tunnel", mustParse(t, "4\n##start\ns 0 0\n##end\ne 1 0\ns-e*2\n", parseOptions{}), 2},
+		{"unreachable", mustParse(t, "1\n##start\ns 0 0\na 1 0\nb 2 0\n##end\ne 3 0\ns-a\nb-e\n", parseOptions{}), 0},
+	}
+	for _, tt := range tests {
+		if got := lowerBoundTurns(tt.graph, tt.graph.AntCount); got != tt.want {
+			t.Errorf("%s: lower bound = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// No solver may beat the bound.
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		if bound := lowerBoundTurns(graph, graph.AntCount); bound > optimalTurns[name] || bound <= 0 {
+			t.Errorf("%s: lower bound of %d turns, solved in %d", name, bound, optimalTurns[name])
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "example00.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout, _ := solveText(t, string(data), options{verbose: true}); !strings.Contains(stdout, "Lower bound: 6 turns, optimal\n") {
+		t.Errorf("-v output does not call 6 turns optimal:\n%s", stdout)
+	}
+	// Disjoint paths leave the shared room half empty.
+	if stdout, _ := solveText(t, sharedRoomMap, options{verbose: true}); !strings.Contains(stdout, "Lower bound: 7 turns\n") {
+		t.Errorf("-v output:\n%s\nwant a lower bound of 7 turns", stdout)
+	}
+}
+
+// diamondMap has two symmetric sides, a and b, between the start and end,
+// followed by a second diamond with sides c and d.
+const diamondMap = `2
+##start
+s 0 0
+a 1 1
+b 1 -1
+m 2 0
+c 3 1
+d 3 -1
+##end
+e 4 0
+s-a
+s-b
+a-m
+b-m
+m-c
+m-d
+c-e
+d-e
+`
+
+func TestSymmetricRooms(t *testing.T) {
+	graph := mustParse(t, diamondMap, parseOptions{})
+	want := [][]string{{"a", "b"}, {"c", "d"}}
+	if got := findSymmetricRooms(graph); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("symmetric rooms = %v, want %v", got, want)
+	}
+	if got := findSymmetricRooms(mustParse(t, funnelMap, parseOptions{})); len(got) != 1 {
+		t.Errorf("funnel: symmetric rooms = %v, want a~b", got)
+	}
+	// Lopsided sides are not interchangeable.
+	lopsided := mustParse(t, diamondMap+"a-d\n", parseOptions{})
+	if got := findSymmetricRooms(lopsided); len(got) != 0 {
+		t.Errorf("lopsided: symmetric rooms = %v, want none", got)
+	}
+
+	stdout, _ := solveText(t, diamondMap, options{verbose: true})
+	if !strings.Contains(stdout, "Symmetric branches: a~b, c~d (") {
+		t.Errorf("-v output lacks the symmetry hint:\n%s", stdout)
+	}
+	if stdout, _ := solveText(t, diamondMap, options{}); strings.Contains(stdout, "Symmetric") {
+		t.Errorf("output without -v has the symmetry hint:\n%s", stdout)
+	}
+}
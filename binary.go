@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// binaryMagic starts every map in the binary format.
+//
+// The binary format stores a map compactly for very large inputs. All
+// integers are little-endian:
+//
+//	magic       4 bytes  "LEMB"
+//	ants        uint32
+//	room count  uint32
+//	rooms       for each room:
+//	              name length  uint32
+//	              name         name length bytes of UTF-8
+//	              x, y         int32 each
+//	              flags        uint8: 1 = start, 2 = end
+//	link count  uint32
+//	links       for each link: two uint32 room indices, in room order
+//
+// Room capacities, tags and fractional coordinates, tunnel weights and
+// widths, and one-way tunnels are not stored; writeBinary refuses maps that
+// use any of them rather than change how they are solved.
+var binaryMagic = []byte("LEMB")
+
+// maxBinaryNameLength bounds the room names loadBinary accepts, so a corrupt
+// length cannot make it allocate without limit.
+const maxBinaryNameLength = 1 << 16
+
+const (
+	binaryStart = 1 << iota
+	binaryEnd
+)
+
+// loadBinary reads a map in the binary format.
+func loadBinary(r io.Reader) (*Graph, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, binaryMagic) {
+		return nil, errors.New("not a binary map")
+	}
+
+	read := func(data any) error {
+		if err := binary.Read(br, binary.LittleEndian, data); err != nil {
+			return fmt.Errorf("truncated binary map: %w", err)
+		}
+		return nil
+	}
+
+	graph := NewGraph()
+	var ants, roomCount uint32
+	if err := read(&ants); err != nil {
+		return nil, err
+	}
+	if err := read(&roomCount); err != nil {
+		return nil, err
+	}
+	if ants == 0 {
+		return nil, fmt.Errorf("invalid number of ants")
+	}
+	graph.AntCount = int(ants)
+
+	var names []string
+	for i := uint32(0); i < roomCount; i++ {
+		var nameLength uint32
+		if err := read(&nameLength); err != nil {
+			return nil, err
+		}
+		if nameLength > maxBinaryNameLength {
+			return nil, fmt.Errorf("room name too long in binary map: %d bytes", nameLength)
+		}
+		name := make([]byte, nameLength)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return nil, fmt.Errorf("truncated binary map: %w", err)
+		}
+		var room struct {
+			X, Y  int32
+			Flags uint8
+		}
+		if err := read(&room); err != nil {
+			return nil, err
+		}
+		if err := validateRoomName(string(name), 0); err != nil {
+			return nil, err
+		}
+		if err := graph.AddRoom(string(name), int(room.X), int(room.Y), room.Flags&binaryStart != 0, room.Flags&binaryEnd != 0); err != nil {
+			return nil, err
+		}
+		names = append(names, string(name))
+	}
+
+	var linkCount uint32
+	if err := read(&linkCount); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < linkCount; i++ {
+		var link [2]uint32
+		if err := read(&link); err != nil {
+			return nil, err
+		}
+		if link[0] >= roomCount || link[1] >= roomCount {
+			return nil, fmt.Errorf("invalid room index in binary map link: %d-%d", link[0], link[1])
+		}
+		if link[0] == link[1] {
+			return nil, fmt.Errorf("self referencing room: %s", names[link[0]])
+		}
+		if err := graph.AddConnection(names[link[0]], names[link[1]]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := graph.Validate(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// writeBinary writes the graph in the binary format, with rooms in name order.
+func writeBinary(w io.Writer, graph *Graph) error {
+	names := make([]string, 0, len(graph.Rooms))
+	for name := range graph.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	index := make(map[string]uint32, len(names))
+	for i, name := range names {
+		index[name] = uint32(i)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	write := func(data any) {
+		binary.Write(&buf, binary.LittleEndian, data)
+	}
+	write(uint32(graph.AntCount))
+	write(uint32(len(names)))
+	for _, name := range names {
+		room := graph.Rooms[name]
+		if room.X != int(int32(room.X)) || room.Y != int(int32(room.Y)) || room.FX != float64(room.X) || room.FY != float64(room.Y) {
+			return fmt.Errorf("coordinates of room %s do not fit the binary format", name)
+		}
+		if room.Capacity != 1 {
+			return fmt.Errorf("capacity of room %s does not fit the binary format", name)
+		}
+		if len(room.Tags) > 0 {
+			return fmt.Errorf("tags of room %s do not fit the binary format", name)
+		}
+		var flags uint8
+		if room.IsStart {
+			flags |= binaryStart
+		}
+		if room.IsEnd {
+			flags |= binaryEnd
+		}
+		write(uint32(len(name)))
+		buf.WriteString(name)
+		write(int32(room.X))
+		write(int32(room.Y))
+		write(flags)
+	}
+
+	var links [][2]uint32
+	for _, name := range names {
+		for _, neighbor := range graph.Connections[name] {
+			if graph.OneWay(name, neighbor) {
+				return fmt.Errorf("one-way tunnel %s->%s does not fit the binary format", name, neighbor)
+			}
+			if graph.Weight(name, neighbor) != 1 {
+				return fmt.Errorf("weight of tunnel %s-%s does not fit the binary format", name, neighbor)
+			}
+			if graph.Width(name, neighbor) != 1 {
+				return fmt.Errorf("width of tunnel %s-%s does not fit the binary format", name, neighbor)
+			}
+			// Each tunnel is stored in both directions; write it once.
+			if name < neighbor {
+				links = append(links, [2]uint32{index[name], index[neighbor]})
+			}
+		}
+	}
+	write(uint32(len(links)))
+	write(links)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		var buf bytes.Buffer
+		if err := writeBinary(&buf, graph); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		loaded, err := loadBinary(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if diff := graph.Diff(loaded); diff != nil {
+			t.Errorf("%s: loaded map differs: %v", name, diff)
+		}
+
+		// readInput recognizes the format by its magic header, whatever the
+		// file is called.
+		file := filepath.Join(dir, strings.TrimSuffix(name, ".txt")+".map")
+		if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		read, lines, err := readInput(file, parseOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if lines != nil || !graph.Equal(read) {
+			t.Errorf("%s: readInput did not load the binary map", name)
+		}
+	}
+}
+
+func TestLoadBinaryErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeBinary(&buf, readExample(t, "example00.txt")); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, err := loadBinary(bytes.NewReader([]byte("LEMX"))); err == nil || err.Error() != "not a binary map" {
+		t.Errorf("bad magic: err = %v", err)
+	}
+	for _, n := range []int{6, 12, 20, len(data) - 1} {
+		_, err := loadBinary(bytes.NewReader(data[:n]))
+		if err == nil || !strings.HasPrefix(err.Error(), "truncated binary map") {
+			t.Errorf("map cut to %d bytes: err = %v", n, err)
+		}
+	}
+
+	// The first room name claims to be far longer than any allowed.
+	huge := bytes.Clone(data)
+	copy(huge[12:], []byte{0xff, 0xff, 0xff, 0x7f})
+	if _, err := loadBinary(bytes.NewReader(huge)); err == nil || !strings.Contains(err.Error(), "room name too long") {
+		t.Errorf("huge name length: err = %v", err)
+	}
+}
+
+func FuzzLoadBinary(f *testing.F) {
+	for _, name := range exampleMaps {
+		var buf bytes.Buffer
+		if err := writeBinary(&buf, readExample(f, name)); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		graph, err := loadBinary(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkParsed(t, graph)
+		// Whatever loads writes back out to the same map.
+		var buf bytes.Buffer
+		if err := writeBinary(&buf, graph); err != nil {
+			t.Fatal(err)
+		}
+		again, err := loadBinary(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := graph.Diff(again); diff != nil {
+			t.Fatalf("map changed on writing it again: %v", diff)
+		}
+	})
+}
+
+func TestBinaryRefusesUnstoredFeatures(t *testing.T) {
+	const base = "3\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\na-e\ns-b\nb-e\n"
+	plain := mustParse(t, base, parseOptions{})
+	var buf bytes.Buffer
+	if err := writeBinary(&buf, plain); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := plain.Diff(loaded); diff != nil {
+		t.Errorf("plain map changed in a round trip: %v", diff)
+	}
+
+	tests := []struct {
+		name, text, want string
+		opts             parseOptions
+	}{
+		{"capacity", strings.Replace(base, "a 1 0", "a 1 0 2", 1), "capacity of room a does not fit the binary format", parseOptions{}},
+		{"tags", strings.Replace(base, "a 1 0", "# kind:nest\na 1 0", 1), "tags of room a do not fit the binary format", parseOptions{tags: true}},
+		{"fractional coordinates", strings.Replace(base, "a 1 0", "a 1.5 0", 1), "coordinates of room a do not fit the binary format", parseOptions{floatCoords: true}},
+		{"weight", strings.Replace(base, "a-e", "a-e:4", 1), "weight of tunnel a-e does not fit the binary format", parseOptions{}},
+		{"width", strings.Replace(base, "a-e", "a-e*2", 1), "width of tunnel a-e does not fit the binary format", parseOptions{}},
+		{"one-way", strings.Replace(base, "a-e", "a->e", 1), "one-way tunnel a->e does not fit the binary format", parseOptions{}},
+	}
+	for _, tt := range tests {
+		graph := mustParse(t, tt.text, tt.opts)
+		if plain.Equal(graph) {
+			t.Fatalf("%s: map is the same as the plain one", tt.name)
+		}
+		err := writeBinary(&bytes.Buffer{}, graph)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
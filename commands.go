@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// commands lists the subcommands, with the optional second argument each
+// takes. Without a subcommand the map is solved.
+var commands = map[string]string{
+	"solve":     "",
+	"validate":  "[moves_file]",
+	"visualize": "[moves_file]",
+	"render":    "[output_file.svg|.dot]",
+}
+
+// validateCommand checks that the map can be solved and, given a file of
+// moves, that the moves solve it.
+func validateCommand(graph *Graph, movesFile string) error {
+	if findShortestPath(graph) == nil {
+		return fmt.Errorf("no path from %s to %s", graph.StartRoom, graph.EndRoom)
+	}
+	if movesFile == "" {
+		fmt.Println("Map is valid.")
+		return nil
+	}
+
+	file, err := os.Open(movesFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	turns, err := readMoves(file)
+	if err != nil {
+		return err
+	}
+	if err := validateMoves(graph, turns); err != nil {
+		return err
+	}
+	fmt.Printf("Moves are valid: all %d ants reach %s in %d turns.\n", graph.AntCount, graph.EndRoom, len(turns))
+	return nil
+}
+
+// visualizeCommand plays the moves in movesFile over the map, or, without a
+// file, the moves of the solver's own solution.
+func visualizeCommand(opts options, solver Solver, graph *Graph, movesFile string) error {
+	var turns [][]string
+	if movesFile != "" {
+		file, err := os.Open(movesFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if turns, err = readMoves(file); err != nil {
+			return err
+		}
+	} else {
+		result, err := solver.Solve(graph)
+		if err != nil {
+			return err
+		}
+		turns = result.Moves
+	}
+	// Escape codes would only clutter a file or pipe.
+	color := opts.color && isTerminal(os.Stdout)
+	return visualizeAntMovements(os.Stdout, graph, turns, color)
+}
+
+// renderCommand writes the map to outputFile as an SVG image if it is named
+// .svg, or in DOT format if it is named .dot or .gv; without an output file
+// it writes DOT to stdout. Graphviz lays DOT out anew, e.g. "dot -Tpng
+// map.dot", while the SVG places the rooms at their coordinates.
+func renderCommand(graph *Graph, outputFile string) error {
+	if outputFile == "" {
+		return graph.ToDOT(os.Stdout)
+	}
+	var render func(io.Writer) error
+	switch filepath.Ext(outputFile) {
+	case ".svg":
+		render = graph.ToSVG
+	case ".dot", ".gv":
+		render = graph.ToDOT
+	default:
+		return fmt.Errorf("render writes .svg, .dot or .gv files, not %s", outputFile)
+	}
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := render(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
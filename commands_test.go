@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubcommands(t *testing.T) {
+	mapFile := filepath.Join("testdata", "example00.txt")
+	dotFile := filepath.Join(t.TempDir(), "farm.dot")
+	svgFile := filepath.Join(t.TempDir(), "farm.svg")
+	tests := []struct {
+		args    []string
+		command string
+		second  string
+		want    string // a line the handler prints
+	}{
+		{[]string{mapFile}, "", "", "L1-2\n"},
+		{[]string{"solve", mapFile}, "solve", "", "L1-2\n"},
+		{[]string{"validate", mapFile}, "validate", "", "Map is valid.\n"},
+		{[]string{"visualize", mapFile}, "visualize", "", "Turn 1: "},
+		{[]string{"render", mapFile}, "render", "", "graph lemin {\n"},
+		{[]string{"render", mapFile, dotFile}, "render", dotFile, ""},
+		{[]string{"render", mapFile, svgFile}, "render", svgFile, ""},
+	}
+	for _, tt := range tests {
+		opts, err := parseArgs(tt.args)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if opts.command != tt.command || opts.filename != mapFile || opts.second != tt.second {
+			t.Errorf("%v: command %q on %q and %q", tt.args, opts.command, opts.filename, opts.second)
+		}
+		stdout, err := captureStdout(t, func() error { return run(opts) })
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+		}
+		if !strings.Contains(stdout, tt.want) {
+			t.Errorf("%v printed:\n%s\nwant %q", tt.args, stdout, tt.want)
+		}
+		if tt.command == "render" && strings.Contains(stdout, "L1-2") {
+			t.Errorf("%v solved the map", tt.args)
+		}
+	}
+
+	dot, err := os.ReadFile(dotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(dot), "graph lemin {\n") {
+		t.Errorf("render wrote:\n%s", dot)
+	}
+	svg, err := os.ReadFile(svgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg ") || !strings.HasSuffix(string(svg), "</svg>\n") {
+		t.Errorf("render wrote:\n%s", svg)
+	}
+}
+
+func TestSubcommandErrors(t *testing.T) {
+	mapFile := filepath.Join("testdata", "example00.txt")
+	for _, args := range [][]string{
+		{"solve", mapFile, "extra.txt"},
+		{"validate"},
+		{"render", mapFile, "out.dot", "extra"},
+	} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q): no error", args)
+		}
+	}
+
+	opts, err := parseArgs([]string{"render", mapFile, filepath.Join(t.TempDir(), "farm.png")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "render writes .svg, .dot or .gv files") {
+		t.Errorf("rendering to .png: err = %v", err)
+	}
+}
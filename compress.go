@@ -0,0 +1,78 @@
+package main
+
+import "slices"
+
+// compressMoves merges each turn into the turn before it whenever the merged
+// turn is still legal, reducing the number of turns. Merging only moves a
+// turn's moves earlier; the ants end up where they did before, so the turns
+// that follow stay legal. When maxMovesPerTurn is positive, turns are not
+// merged past that many moves. The result is validated again before
+// returning.
+func compressMoves(graph *Graph, turns [][]string, maxMovesPerTurn int) ([][]string, error) {
+	var compressed [][]string
+	before := newMoveValidator(graph) // state before the last compressed turn
+	after := newMoveValidator(graph)  // state after it
+	for _, moves := range turns {
+		if len(compressed) > 0 && (maxMovesPerTurn <= 0 || len(compressed[len(compressed)-1])+len(moves) <= maxMovesPerTurn) {
+			last := len(compressed) - 1
+			merged := append(slices.Clone(compressed[last]), moves...)
+			trial := before.clone()
+			if trial.step(merged) == nil {
+				compressed[last] = merged
+				after = trial
+				continue
+			}
+		}
+		before = after.clone()
+		if err := after.step(moves); err != nil {
+			return nil, err
+		}
+		compressed = append(compressed, moves)
+	}
+	if err := validateMoves(graph, compressed); err != nil {
+		return nil, err
+	}
+	return compressed, nil
+}
+
+// writeCompressedMoves simulates the ants like writeAntMoves, but collects the
+// turns and compresses them with compressMoves before writing them to out.
+// The observers are called with the compressed turns, as they are written, so
+// that traces and usage counts agree with the output. It returns the number
+// of turns written.
+func writeCompressedMoves(out *moveWriter, graph *Graph, assignment map[int][]string, sim simOptions, observers ...func([]antMove)) (int, error) {
+	var turns [][]string
+	_, err := simulateAntMoves(graph, assignment, sim, func(moves []antMove) error {
+		turns = append(turns, moveStrings(moves))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	compressed, err := compressMoves(graph, turns, sim.maxMovesPerTurn)
+	if err != nil {
+		return 0, err
+	}
+	positions := make(map[int]string)
+	for _, moves := range compressed {
+		turn := make([]antMove, len(moves))
+		for i, text := range moves {
+			// compressMoves validated the moves, so they parse.
+			ant, room, _ := parseMove(text)
+			from, ok := positions[ant]
+			if !ok {
+				from = graph.StartRoom
+			}
+			turn[i] = antMove{Ant: ant, From: from, To: room}
+			positions[ant] = room
+		}
+		for _, observe := range observers {
+			observe(turn)
+		}
+		if err := out.writeTurn(moves); err != nil {
+			return 0, err
+		}
+	}
+	return len(compressed), nil
+}
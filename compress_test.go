@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestCompressMoves(t *testing.T) {
+	twoPaths := mustParse(t, "2\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\na-e\ns-b\nb-e\n", parseOptions{})
+	chain := mustParse(t, "2\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n", parseOptions{})
+	// The ants on the two paths could have moved side by side.
+	staggered := [][]string{{"L1-a"}, {"L2-b"}, {"L1-e"}, {"L2-e"}}
+
+	tests := []struct {
+		name  string
+		graph *Graph
+		turns [][]string
+		limit int
+		want  [][]string
+	}{
+		{"two paths", twoPaths, staggered, 0, [][]string{{"L1-a", "L2-b"}, {"L1-e", "L2-e"}}},
+		{"cap of two", twoPaths, staggered, 2, [][]string{{"L1-a", "L2-b"}, {"L1-e", "L2-e"}}},
+		{"cap of one", twoPaths, staggered, 1, staggered},
+		// L2 cannot enter a before L1 leaves it, and L1 cannot move twice.
+		{"chain", chain, [][]string{{"L1-a"}, {"L1-e", "L2-a"}, {"L2-e"}}, 0, [][]string{{"L1-a"}, {"L1-e", "L2-a"}, {"L2-e"}}},
+	}
+	for _, tt := range tests {
+		got, err := compressMoves(tt.graph, tt.turns, tt.limit)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: compressed to %v, want %v", tt.name, got, tt.want)
+		}
+		if err := validateMoves(tt.graph, got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	if _, err := compressMoves(chain, [][]string{{"L1-e"}}, 0); err == nil {
+		t.Error("compressing illegal moves: no error")
+	}
+}
+
+func TestCompressRespectsCap(t *testing.T) {
+	graph := readExample(t, "example05.txt")
+	result, err := DFSSolver{}.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []int{0, 1, 3} {
+		compressed, err := compressMoves(graph, result.Moves, limit)
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		if len(compressed) > len(result.Moves) {
+			t.Errorf("limit %d: %d turns became %d", limit, len(result.Moves), len(compressed))
+		}
+		// A turn over the cap must be one the simulation made, not a merge.
+		for i, moves := range compressed {
+			if limit > 0 && len(moves) > limit && !slices.ContainsFunc(result.Moves, func(turn []string) bool { return slices.Equal(turn, moves) }) {
+				t.Errorf("limit %d: turn %d merged to %d moves", limit, i+1, len(moves))
+			}
+		}
+	}
+}
+
+func TestCompressedObservers(t *testing.T) {
+	graph := mustParse(t, "2\n##start\ns 0 0\nx 1 0\nc 2 0\n##end\ne 3 0\ns-x\nx-c\ns-c\nc-e\n", parseOptions{})
+	// Ant 1 reaches c as ant 2 leaves it, so the simulation holds it back a
+	// turn that compression wins back.
+	assignment := map[int][]string{1: {"s", "x", "c", "e"}, 2: {"s", "c", "e"}}
+
+	var observed [][]antMove
+	tracer := newAntTracer(graph.StartRoom)
+	var buf bytes.Buffer
+	out := &moveWriter{w: bufio.NewWriter(&buf)}
+	turns, err := writeCompressedMoves(out, graph, assignment, simOptions{}, tracer.record, func(moves []antMove) {
+		observed = append(observed, moves)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "L1-x L2-c\nL2-e L1-c\nL1-e\n"; buf.String() != want || turns != 3 {
+		t.Fatalf("wrote %d turns:\n%s\nwant:\n%s", turns, buf.String(), want)
+	}
+	want := [][]antMove{
+		{{Ant: 1, From: "s", To: "x"}, {Ant: 2, From: "s", To: "c"}},
+		{{Ant: 2, From: "c", To: "e"}, {Ant: 1, From: "x", To: "c"}},
+		{{Ant: 1, From: "c", To: "e"}},
+	}
+	if !slices.EqualFunc(observed, want, slices.Equal) {
+		t.Errorf("observed %v, want the written turns %v", observed, want)
+	}
+	if got, want := tracer.trace(1), "Ant 1: s(t0) -> x(t1) -> c(t2) -> e(t3)"; got != want {
+		t.Errorf("trace = %q, want %q", got, want)
+	}
+}
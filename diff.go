@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Equal reports whether two graphs describe the same farm. The order of each
+// room's tunnels does not matter.
+func (g *Graph) Equal(other *Graph) bool {
+	return len(g.Diff(other)) == 0
+}
+
+// Diff describes how other differs from g, one difference per line in a
+// stable order, e.g. "room b: only in first". It returns nil when the graphs
+// are equal. The order of each room's tunnels does not matter.
+func (g *Graph) Diff(other *Graph) []string {
+	var diffs []string
+	if g.AntCount != other.AntCount {
+		diffs = append(diffs, fmt.Sprintf("ant count: %d != %d", g.AntCount, other.AntCount))
+	}
+	if g.StartRoom != other.StartRoom {
+		diffs = append(diffs, fmt.Sprintf("start room: %s != %s", g.StartRoom, other.StartRoom))
+	}
+	if g.EndRoom != other.EndRoom {
+		diffs = append(diffs, fmt.Sprintf("end room: %s != %s", g.EndRoom, other.EndRoom))
+	}
+
+	for _, name := range roomNames(g, other) {
+		room, inFirst := g.Rooms[name]
+		otherRoom, inSecond := other.Rooms[name]
+		switch {
+		case !inSecond:
+			diffs = append(diffs, fmt.Sprintf("room %s: only in first", name))
+			continue
+		case !inFirst:
+			diffs = append(diffs, fmt.Sprintf("room %s: only in second", name))
+			continue
+		case !sameRoom(room, otherRoom):
+			diffs = append(diffs, fmt.Sprintf("room %s: %+v != %+v", name, room, otherRoom))
+		}
+
+		links := slices.Clone(g.Connections[name])
+		otherLinks := slices.Clone(other.Connections[name])
+		sort.Strings(links)
+		sort.Strings(otherLinks)
+		if !slices.Equal(links, otherLinks) {
+			diffs = append(diffs, fmt.Sprintf("links of %s: %v != %v", name, links, otherLinks))
+			continue
+		}
+		for _, neighbor := range links {
+			if weight, otherWeight := g.Weight(name, neighbor), other.Weight(name, neighbor); g.lists(name, neighbor) && weight != otherWeight {
+				diffs = append(diffs, fmt.Sprintf("weight of %s-%s: %d != %d", name, neighbor, weight, otherWeight))
+			}
+			if width, otherWidth := g.Width(name, neighbor), other.Width(name, neighbor); g.lists(name, neighbor) && width != otherWidth {
+				diffs = append(diffs, fmt.Sprintf("width of %s-%s: %d != %d", name, neighbor, width, otherWidth))
+			}
+		}
+	}
+	return diffs
+}
+
+// sameRoom reports whether two rooms are equal, tags included.
+func sameRoom(a, b Room) bool {
+	return a.Name == b.Name && a.X == b.X && a.Y == b.Y && a.FX == b.FX && a.FY == b.FY &&
+		a.IsStart == b.IsStart && a.IsEnd == b.IsEnd && a.Capacity == b.Capacity &&
+		maps.Equal(a.Tags, b.Tags)
+}
+
+// roomNames returns the names of the rooms in either graph, in name order.
+func roomNames(a, b *Graph) []string {
+	seen := make(map[string]bool, len(a.Rooms))
+	var names []string
+	for _, rooms := range []map[string]Room{a.Rooms, b.Rooms} {
+		for name := range rooms {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEqualIgnoresLinkOrder(t *testing.T) {
+	a := mustParse(t, "2\n##start\ns 0 0\nx 1 0\ny 1 1\n##end\ne 2 0\ns-x\ns-y\nx-e\ny-e\n", parseOptions{})
+	b := mustParse(t, "2\n##start\ns 0 0\ny 1 1\nx 1 0\n##end\ne 2 0\ny-e\nx-e\ns-y\ns-x\n", parseOptions{})
+	if slices.Equal(a.Connections["s"], b.Connections["s"]) {
+		t.Fatal("the maps list the tunnels of s in the same order")
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("graphs differing only in link order are not equal: %v", a.Diff(b))
+	}
+	if diff := a.Diff(b); diff != nil {
+		t.Errorf("Diff = %v, want nil", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const base = "2\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n"
+	tests := []struct {
+		name  string
+		other string
+		want  []string
+	}{
+		{"ants", "3\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n", []string{"ant count: 2 != 3"}},
+		{"moved room", "2\n##start\ns 0 0\na 5 0\n##end\ne 2 0\ns-a\na-e\n", []string{"room a: {Name:a X:1 Y:0"}},
+		{"extra room", "2\n##start\ns 0 0\na 1 0\nb 3 0\n##end\ne 2 0\ns-a\na-e\n", []string{"room b: only in second"}},
+		{"extra link", "2\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\ns-e\n", []string{"links of e: [a] != [a s]", "links of s: [a] != [a e]"}},
+		{"end", "2\n##start\ns 0 0\n##end\na 1 0\ne 2 0\ns-a\na-e\n", []string{"end room: e != a"}},
+	}
+	graph := mustParse(t, base, parseOptions{})
+	for _, tt := range tests {
+		other := mustParse(t, tt.other, parseOptions{})
+		if graph.Equal(other) {
+			t.Errorf("%s: graphs are equal", tt.name)
+		}
+		diff := graph.Diff(other)
+		for _, want := range tt.want {
+			if !slices.ContainsFunc(diff, func(line string) bool { return strings.HasPrefix(line, want) }) {
+				t.Errorf("%s: Diff = %q, want a line starting %q", tt.name, diff, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToDOT writes the graph in Graphviz DOT format. Rooms become nodes, with the
+// start and end rooms styled distinctly, and each tunnel becomes one edge,
+// with an arrow if it leads one way.
+// Room coordinates are written as pinned pos attributes, which neato honours
+// and dot ignores.
+func (g *Graph) ToDOT(w io.Writer) error {
+	names := make([]string, 0, len(g.Rooms))
+	for name := range g.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("graph lemin {\n")
+	for _, name := range names {
+		room := g.Rooms[name]
+		fmt.Fprintf(&buf, "\t%q [pos=\"%s,%s!\"", name, formatCoordinate(room.FX), formatCoordinate(room.FY))
+		switch {
+		case room.IsStart:
+			buf.WriteString(", shape=doublecircle, color=green")
+		case room.IsEnd:
+			buf.WriteString(", shape=doublecircle, color=red")
+		}
+		buf.WriteString("];\n")
+	}
+	for _, name := range names {
+		neighbors := append([]string(nil), g.Connections[name]...)
+		sort.Strings(neighbors)
+		for _, neighbor := range neighbors {
+			// Each two-way tunnel is stored in both directions; write it
+			// once.
+			if !g.lists(name, neighbor) {
+				continue
+			}
+			fmt.Fprintf(&buf, "\t%q -- %q", name, neighbor)
+			var attrs []string
+			if weight := g.Weight(name, neighbor); weight != 1 {
+				attrs = append(attrs, fmt.Sprintf("label=\"%d\"", weight))
+			}
+			if g.OneWay(name, neighbor) {
+				attrs = append(attrs, "dir=forward")
+			}
+			if len(attrs) > 0 {
+				fmt.Fprintf(&buf, " [%s]", strings.Join(attrs, ", "))
+			}
+			buf.WriteString(";\n")
+		}
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// formatCoordinate writes a coordinate in plain decimal notation, without
+// trailing zeros, e.g. "3" or "1.5".
+func formatCoordinate(c float64) string {
+	return strconv.FormatFloat(c, 'f', -1, 64)
+}
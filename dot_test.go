@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToDOT(t *testing.T) {
+	graph := readExample(t, "example01.txt")
+	var buf bytes.Buffer
+	if err := graph.ToDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+
+	for name, room := range graph.Rooms {
+		node := fmt.Sprintf("\t%q [pos=\"%d,%d!\"", name, room.X, room.Y)
+		if count := strings.Count(dot, node); count != 1 {
+			t.Errorf("room %s appears %d times, want once", name, count)
+		}
+	}
+	if !strings.Contains(dot, `"start" [pos="1,6!", shape=doublecircle, color=green]`) {
+		t.Error("start room is not styled as the start")
+	}
+	if !strings.Contains(dot, `"end" [pos="11,6!", shape=doublecircle, color=red]`) {
+		t.Error("end room is not styled as the end")
+	}
+
+	edges := 0
+	for name, neighbors := range graph.Connections {
+		for _, neighbor := range neighbors {
+			a, b := min(name, neighbor), max(name, neighbor)
+			if count := strings.Count(dot, fmt.Sprintf("%q -- %q", a, b)); count != 1 {
+				t.Errorf("edge %s-%s appears %d times, want once", a, b, count)
+			}
+			if count := strings.Count(dot, fmt.Sprintf("%q -- %q", b, a)); count != 0 {
+				t.Errorf("edge %s-%s also appears reversed", a, b)
+			}
+			edges++
+		}
+	}
+	if count := strings.Count(dot, " -- "); count != edges/2 {
+		t.Errorf("DOT has %d edges, want %d", count, edges/2)
+	}
+}
+
+func TestNegativeAndLargeCoordinates(t *testing.T) {
+	graph := mustParse(t, "1\n##start\ns -5 -3\na 0 -1\n##end\ne 9999999999 7\ns-a\na-e\n", parseOptions{})
+	for name, want := range map[string][2]int{"s": {-5, -3}, "a": {0, -1}, "e": {9999999999, 7}} {
+		if room := graph.Rooms[name]; room.X != want[0] || room.Y != want[1] {
+			t.Errorf("room %s at %d,%d, want %d,%d", name, room.X, room.Y, want[0], want[1])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := graph.ToDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []string{`"s" [pos="-5,-3!"`, `"a" [pos="0,-1!"`, `"e" [pos="9999999999,7!"`} {
+		if !strings.Contains(buf.String(), node) {
+			t.Errorf("DOT lacks %s:\n%s", node, buf.String())
+		}
+	}
+
+	// The binary format stores 32-bit coordinates.
+	err := writeBinary(&bytes.Buffer{}, graph)
+	if err == nil || err.Error() != "coordinates of room e do not fit the binary format" {
+		t.Errorf("writeBinary: err = %v", err)
+	}
+	small := mustParse(t, "1\n##start\ns -5 -3\na 0 -1\n##end\ne -2147483648 2147483647\ns-a\na-e\n", parseOptions{})
+	buf.Reset()
+	if err := writeBinary(&buf, small); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := small.Diff(loaded); diff != nil {
+		t.Errorf("negative coordinates changed in the binary format: %v", diff)
+	}
+}
+
+func TestFloatCoordinates(t *testing.T) {
+	const farm = "1\n##start\ns 0 0\nroom 1.5 2.5\n##end\ne -3.25 4\ns-room\nroom-e\n"
+	if _, _, err := parseMap(strings.NewReader(farm), parseOptions{}); err == nil {
+		t.Error("fractional coordinates parsed without -float-coords")
+	}
+
+	graph := mustParse(t, farm, parseOptions{floatCoords: true})
+	for name, want := range map[string][4]float64{"s": {0, 0, 0, 0}, "room": {1.5, 2.5, 2, 3}, "e": {-3.25, 4, -3, 4}} {
+		room := graph.Rooms[name]
+		if room.FX != want[0] || room.FY != want[1] {
+			t.Errorf("room %s at %v,%v, want %v,%v", name, room.FX, room.FY, want[0], want[1])
+		}
+		if float64(room.X) != want[2] || float64(room.Y) != want[3] {
+			t.Errorf("room %s rounded to %d,%d, want %v,%v", name, room.X, room.Y, want[2], want[3])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := graph.ToDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"room" [pos="1.5,2.5!"`) {
+		t.Errorf("DOT does not place room at its exact coordinates:\n%s", buf.String())
+	}
+}
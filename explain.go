@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// explainPaths writes, for each candidate path, whether it is one of the
+// chosen paths and, if not, which chosen path it shares a room with. Chosen
+// paths are numbered as debugPaths numbers them.
+func explainPaths(w io.Writer, graph *Graph, candidates, chosen [][]string) {
+	roomSets := make([]map[string]bool, len(chosen))
+	for i, path := range chosen {
+		roomSets[i] = intermediateRooms(path, graph.StartRoom, graph.EndRoom)
+	}
+
+	fmt.Fprintln(w, "Candidate paths:")
+	for _, candidate := range candidates {
+		fmt.Fprintf(w, "%s: %s\n", strings.Join(candidate, " -> "), explainPath(graph, candidate, chosen, roomSets))
+	}
+}
+
+// explainPath describes why a single candidate path was or wasn't chosen.
+func explainPath(graph *Graph, candidate []string, chosen [][]string, roomSets []map[string]bool) string {
+	for i, path := range chosen {
+		if slices.Equal(candidate, path) {
+			return fmt.Sprintf("included as path %d", i+1)
+		}
+	}
+	for _, room := range candidate {
+		for i, rooms := range roomSets {
+			if rooms[room] {
+				return fmt.Sprintf("conflicts with path %d at room %s", i+1, room)
+			}
+		}
+	}
+	return "compatible, but not needed"
+}
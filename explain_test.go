@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplainPaths(t *testing.T) {
+	// Both paths pass through a, so only the shorter is chosen.
+	const farm = "1\n##start\ns 0 0\na 1 0\nb 2 1\n##end\ne 2 0\ns-a\na-e\na-b\nb-e\n"
+	graph := mustParse(t, farm, parseOptions{})
+	candidates := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1)
+	var buf bytes.Buffer
+	explainPaths(&buf, graph, candidates, [][]string{{"s", "a", "e"}})
+	want := `Candidate paths:
+s -> a -> e: included as path 1
+s -> a -> b -> e: conflicts with path 1 at room a
+`
+	if buf.String() != want {
+		t.Errorf("explanation:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	stdout, _ := solveText(t, farm, options{explain: true})
+	if !strings.Contains(stdout, want) {
+		t.Errorf("-explain output does not hold the explanation:\n%s", stdout)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"slices"
+	"sort"
+)
+
+// flowNetwork is the residual network used to find vertex-disjoint paths.
+// Every room is split into an "in" node and an "out" node joined by an edge
+// of capacity one, so at most one path can pass through it. Every tunnel
+// becomes an edge from the out node of one room to the in node of the other.
+// Edges are stored in pairs: edge e^1 is the reverse of edge e.
+type flowNetwork struct {
+	names    []string       // room name of each pair of nodes
+	index    map[string]int // room name to its position in names
+	edges    [][]int        // ids of the edges leaving each node
+	to       []int          // node each edge leads to
+	capacity []int          // residual capacity of each edge
+	search   bfsSearch      // reused by every call to augment
+}
+
+// newFlowNetwork builds the network for graph.
+func newFlowNetwork(graph *Graph) *flowNetwork {
+	return buildFlowNetwork(graph, false)
+}
+
+// newCapacityNetwork builds the network for graph with each room's edge as
+// wide as the room's capacity and each tunnel's edge as wide as the tunnel, so
+// its maximum flow is the most ants that can reach the end room in one turn.
+// Its flow does not decompose into paths.
+func newCapacityNetwork(graph *Graph) *flowNetwork {
+	return buildFlowNetwork(graph, true)
+}
+
+// buildFlowNetwork builds the network for graph, with unit edges unless
+// capacities is set.
+func buildFlowNetwork(graph *Graph, capacities bool) *flowNetwork {
+	names := make([]string, 0, len(graph.Rooms))
+	for name := range graph.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	n := &flowNetwork{
+		names: names,
+		index: make(map[string]int, len(names)),
+		edges: make([][]int, 2*len(names)),
+	}
+	for i, name := range names {
+		n.index[name] = i
+	}
+	for _, name := range names {
+		// The start and end rooms may hold any number of ants.
+		if !graph.Unlimited(name) {
+			capacity := 1
+			if capacities {
+				capacity = graph.Rooms[name].Capacity
+			}
+			n.addEdge(n.in(name), n.out(name), capacity)
+		}
+		// Adding the edges in name order makes every search, and so the
+		// paths the flow decomposes into, independent of the order the
+		// tunnels were listed in.
+		neighbors := slices.Clone(graph.Connections[name])
+		sort.Strings(neighbors)
+		for _, neighbor := range neighbors {
+			capacity := 1
+			if capacities {
+				capacity = graph.Width(name, neighbor)
+			}
+			n.addEdge(n.out(name), n.in(neighbor), capacity)
+		}
+	}
+	return n
+}
+
+// in returns the node paths enter a room through.
+func (n *flowNetwork) in(room string) int {
+	return 2 * n.index[room]
+}
+
+// out returns the node paths leave a room through.
+func (n *flowNetwork) out(room string) int {
+	return 2*n.index[room] + 1
+}
+
+// addEdge adds an edge of the given capacity and its empty reverse edge.
+func (n *flowNetwork) addEdge(from, to, capacity int) {
+	n.edges[from] = append(n.edges[from], len(n.to))
+	n.to = append(n.to, to)
+	n.capacity = append(n.capacity, capacity)
+	n.edges[to] = append(n.edges[to], len(n.to))
+	n.to = append(n.to, from)
+	n.capacity = append(n.capacity, 0)
+}
+
+// augment finds a shortest augmenting path from source to sink and pushes
+// one unit of flow along it. It reports whether such a path existed.
+func (n *flowNetwork) augment(source, sink int) bool {
+	saturated := func(e int) bool { return n.capacity[e] == 0 }
+	if !n.search.run(n, source, sink, saturated) {
+		return false
+	}
+	for node := sink; node != source; node = n.to[n.search.parent[node]^1] {
+		n.capacity[n.search.parent[node]]--
+		n.capacity[n.search.parent[node]^1]++
+	}
+	return true
+}
+
+// maxFlow pushes as much flow as possible from source to sink with Dinic's
+// algorithm and returns the amount pushed. Each phase labels the nodes with
+// their distance from the source and then saturates every shortest
+// augmenting path at once, so far fewer searches are needed than with
+// augment on large networks.
+func (n *flowNetwork) maxFlow(source, sink int) int {
+	level := make([]int, len(n.edges))
+	next := make([]int, len(n.edges)) // next edge to try from each node
+	flow := 0
+	for n.levels(source, sink, level) {
+		clear(next)
+		for n.push(source, sink, level, next) {
+			flow++
+		}
+	}
+	return flow
+}
+
+// levels sets level to each node's distance from source over edges with
+// capacity left, or -1 for unreachable nodes, and reports whether the sink
+// is reachable.
+func (n *flowNetwork) levels(source, sink int, level []int) bool {
+	for i := range level {
+		level[i] = -1
+	}
+	level[source] = 0
+	queue := []int{source}
+	for head := 0; head < len(queue); head++ {
+		node := queue[head]
+		for _, e := range n.edges[node] {
+			if next := n.to[e]; n.capacity[e] > 0 && level[next] < 0 {
+				level[next] = level[node] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return level[sink] >= 0
+}
+
+// push finds a path from source to sink that climbs one level per edge and
+// pushes one unit of flow along it, reporting whether it found one. Edges
+// that lead nowhere are skipped for the rest of the phase through next. The
+// path is kept as a stack of edges rather than recursed through, so it may
+// be as long as memory allows.
+func (n *flowNetwork) push(source, sink int, level, next []int) bool {
+	var path []int
+	node := source
+	for node != sink {
+		if next[node] == len(n.edges[node]) {
+			// Nothing leads on from node: back up and skip the edge to it.
+			if len(path) == 0 {
+				return false
+			}
+			path = path[:len(path)-1]
+			node = source
+			if len(path) > 0 {
+				node = n.to[path[len(path)-1]]
+			}
+			next[node]++
+			continue
+		}
+		e := n.edges[node][next[node]]
+		if to := n.to[e]; n.capacity[e] > 0 && level[to] == level[node]+1 {
+			path = append(path, e)
+			node = to
+			continue
+		}
+		next[node]++
+	}
+	for _, e := range path {
+		n.capacity[e]--
+		n.capacity[e^1]++
+	}
+	return true
+}
+
+// bfsSearch is a breadth-first search over a flowNetwork whose buffers are
+// kept between runs, so repeated searches on the same network, as when
+// augmenting one path at a time, do not allocate.
+type bfsSearch struct {
+	parent []int // edge used to reach each node in the last run
+	seen   []int // generation in which each node was last reached
+	queue  []int
+	gen    int
+}
+
+// run searches for a shortest route from source to sink that avoids the
+// blocked edges, reporting whether one exists. Afterwards parent holds the
+// edge used to reach each node on the route.
+func (b *bfsSearch) run(n *flowNetwork, source, sink int, blocked func(edge int) bool) bool {
+	if len(b.seen) != len(n.edges) {
+		b.parent = make([]int, len(n.edges))
+		b.seen = make([]int, len(n.edges))
+		b.gen = 0
+	}
+	// Bumping the generation forgets the previous run's visits in O(1).
+	b.gen++
+	b.seen[source] = b.gen
+	b.queue = append(b.queue[:0], source)
+	for head := 0; head < len(b.queue); head++ {
+		node := b.queue[head]
+		for _, e := range n.edges[node] {
+			next := n.to[e]
+			if b.seen[next] == b.gen || blocked(e) {
+				continue
+			}
+			b.seen[next] = b.gen
+			b.parent[next] = e
+			if next == sink {
+				return true
+			}
+			b.queue = append(b.queue, next)
+		}
+	}
+	return false
+}
+
+// paths decomposes the current flow into room paths from source to sink.
+func (n *flowNetwork) paths(source, sink int) [][]string {
+	// A forward edge carries flow when its reverse edge has gained capacity.
+	used := make([]bool, len(n.to))
+	var paths [][]string
+	for _, first := range n.edges[source] {
+		if first%2 != 0 || n.capacity[first^1] == 0 {
+			continue
+		}
+		used[first] = true
+		path := []string{n.names[source/2]}
+		node := n.to[first]
+		for node != sink {
+			if node%2 == 0 {
+				path = append(path, n.names[node/2])
+			}
+			for _, e := range n.edges[node] {
+				if e%2 == 0 && !used[e] && n.capacity[e^1] > 0 {
+					used[e] = true
+					node = n.to[e]
+					break
+				}
+			}
+		}
+		paths = append(paths, append(path, n.names[sink/2]))
+	}
+	return paths
+}
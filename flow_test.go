@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gridMap returns a map of width by height rooms linked to their neighbors,
+// with the start room linked to the left column and the end room to the
+// right one, so it has height disjoint paths.
+func gridMap(width, height, ants int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\n##start\ns 0 0\n##end\ne %d 0\n", ants, width+1)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			fmt.Fprintf(&b, "r%d_%d %d %d\n", x, y, x+1, y+1)
+		}
+	}
+	for y := 0; y < height; y++ {
+		fmt.Fprintf(&b, "s-r0_%d\nr%d_%d-e\n", y, width-1, y)
+		for x := 0; x < width; x++ {
+			if x+1 < width {
+				fmt.Fprintf(&b, "r%d_%d-r%d_%d\n", x, y, x+1, y)
+			}
+			if y+1 < height {
+				fmt.Fprintf(&b, "r%d_%d-r%d_%d\n", x, y, x, y+1)
+			}
+		}
+	}
+	return b.String()
+}
+
+// BenchmarkAugment augments ten paths through a medium grid. The fresh
+// variant throws the search buffers away before each search, as every
+// search did before they were kept, to show the allocations saved.
+func BenchmarkAugment(b *testing.B) {
+	graph := mustParse(b, gridMap(30, 10, 10), parseOptions{})
+	for _, reuse := range []bool{true, false} {
+		name := "reused"
+		if !reuse {
+			name = "fresh"
+		}
+		b.Run(name, func(b *testing.B) {
+			network := newFlowNetwork(graph)
+			source, sink := network.out(graph.StartRoom), network.in(graph.EndRoom)
+			initial := slices.Clone(network.capacity)
+			network.augment(source, sink) // size the search buffers
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(network.capacity, initial)
+				for j := 0; j < 10; j++ {
+					if !reuse {
+						network.search = bfsSearch{}
+					}
+					if !network.augment(source, sink) {
+						b.Fatalf("only %d paths augmented", j)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAugmentDoesNotAllocate(t *testing.T) {
+	graph := mustParse(t, gridMap(30, 10, 10), parseOptions{})
+	network := newFlowNetwork(graph)
+	source, sink := network.out(graph.StartRoom), network.in(graph.EndRoom)
+	initial := slices.Clone(network.capacity)
+	network.augment(source, sink) // let the search size its buffers
+
+	allocs := testing.AllocsPerRun(10, func() {
+		copy(network.capacity, initial)
+		for j := 0; j < 10; j++ {
+			network.augment(source, sink)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("augmenting ten paths allocated %v times, want 0", allocs)
+	}
+	if got := len(network.paths(source, sink)); got != 10 {
+		t.Errorf("%d paths after augmenting, want 10", got)
+	}
+}
+
+// edmondsKarp finds the maximum flow of the network one shortest augmenting
+// path at a time.
+func edmondsKarp(n *flowNetwork, source, sink int) int {
+	flow := 0
+	for n.augment(source, sink) {
+		flow++
+	}
+	return flow
+}
+
+func TestDinicMatchesEdmondsKarp(t *testing.T) {
+	graphs := map[string]*Graph{
+		"grid":       mustParse(t, gridMap(12, 7, 1), parseOptions{}),
+		"layered":    mustParse(t, layeredMap(6, 4, 1), parseOptions{}),
+		"one-way":    mustParse(t, unreachableMap, parseOptions{}),
+		"bottleneck": mustParse(t, funnelMap, parseOptions{}),
+	}
+	for _, name := range exampleMaps {
+		graphs[name] = readExample(t, name)
+	}
+	for name, graph := range graphs {
+		dinic := newFlowNetwork(graph)
+		karp := newFlowNetwork(graph)
+		source, sink := dinic.out(graph.StartRoom), dinic.in(graph.EndRoom)
+		got, want := dinic.maxFlow(source, sink), edmondsKarp(karp, source, sink)
+		if got != want {
+			t.Errorf("%s: Dinic finds %d paths, Edmonds-Karp %d", name, got, want)
+		}
+		if paths := dinic.paths(source, sink); len(paths) != got {
+			t.Errorf("%s: the flow of %d decomposes into %d paths", name, got, len(paths))
+		}
+	}
+}
+
+func BenchmarkMaxFlow(b *testing.B) {
+	graph := mustParse(b, gridMap(100, 100, 1), parseOptions{})
+	network := newFlowNetwork(graph)
+	source, sink := network.out(graph.StartRoom), network.in(graph.EndRoom)
+	initial := slices.Clone(network.capacity)
+	for _, bench := range []struct {
+		name    string
+		maxFlow func(n *flowNetwork, source, sink int) int
+	}{
+		{"dinic", (*flowNetwork).maxFlow},
+		{"edmonds-karp", edmondsKarp},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				copy(network.capacity, initial)
+				if flow := bench.maxFlow(network, source, sink); flow != 100 {
+					b.Fatalf("flow = %d, want 100", flow)
+				}
+			}
+		})
+	}
+}
+
+func TestFlowDeterministic(t *testing.T) {
+	texts := map[string]string{"grid": gridMap(4, 4, 5)}
+	for _, name := range []string{"example01.txt", "example05.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts[name] = string(data)
+	}
+	for name, text := range texts {
+		for algo, solver := range map[string]Solver{"flow": FlowSolver{}, "dinic": DinicSolver{}} {
+			var first [][]string
+			// Each run builds the graph afresh, so no map iteration order
+			// is shared between them.
+			for run := 0; run < 10; run++ {
+				paths, err := solver.ChoosePaths(mustParse(t, text, parseOptions{}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if run == 0 {
+					first = paths
+				} else if !slices.EqualFunc(paths, first, slices.Equal) {
+					t.Fatalf("%s, %s: run %d chose %v, run 0 chose %v", algo, name, run, paths, first)
+				}
+			}
+		}
+	}
+}
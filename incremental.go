@@ -0,0 +1,49 @@
+package main
+
+// IncrementalFlow keeps the flow network of a solved map so that, when a
+// tunnel is added, the vertex-disjoint paths can be updated with a search for
+// the new augmenting paths instead of building and solving the network again.
+type IncrementalFlow struct {
+	graph        *Graph
+	network      *flowNetwork
+	source, sink int
+}
+
+// NewIncrementalFlow finds the maximum set of vertex-disjoint paths of graph.
+// The graph must not be changed other than through AddLink afterwards.
+func NewIncrementalFlow(graph *Graph) *IncrementalFlow {
+	network := newFlowNetwork(graph)
+	f := &IncrementalFlow{
+		graph:   graph,
+		network: network,
+		source:  network.out(graph.StartRoom),
+		sink:    network.in(graph.EndRoom),
+	}
+	for f.network.augment(f.source, f.sink) {
+	}
+	return f
+}
+
+// AddLink adds a tunnel between two existing rooms of the graph and returns
+// how many disjoint paths it added. The existing flow remains valid, so only
+// the augmenting paths through the new tunnel need to be found.
+func (f *IncrementalFlow) AddLink(roomA, roomB string) (int, error) {
+	if err := f.graph.AddConnection(roomA, roomB); err != nil {
+		return 0, err
+	}
+	f.network.addEdge(f.network.out(roomA), f.network.in(roomB), 1)
+	f.network.addEdge(f.network.out(roomB), f.network.in(roomA), 1)
+
+	added := 0
+	for f.network.augment(f.source, f.sink) {
+		added++
+	}
+	return added, nil
+}
+
+// Paths returns the current disjoint paths, shortest first.
+func (f *IncrementalFlow) Paths() [][]string {
+	paths := f.network.paths(f.source, f.sink)
+	sortPaths(paths)
+	return paths
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalFlowAddLink(t *testing.T) {
+	// The bottom row of the grid has no tunnel to the end room until one
+	// is added.
+	text := strings.Replace(gridMap(30, 10, 10), "r29_9-e\n", "", 1)
+	graph := mustParse(t, text, parseOptions{})
+	flow := NewIncrementalFlow(graph)
+	if got := len(flow.Paths()); got != 9 {
+		t.Fatalf("%d paths before the link, want 9", got)
+	}
+
+	searches := flow.network.search.gen
+	added, err := flow.AddLink("r29_9", "e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("AddLink added %d paths, want 1", added)
+	}
+	paths := flow.Paths()
+	if len(paths) != 10 || len(paths) != maxDisjointPaths(graph) {
+		t.Errorf("%d paths after the link, want 10", len(paths))
+	}
+	for i, path := range paths {
+		if err := checkSimplePath(path); err != nil || path[0] != "s" || path[len(path)-1] != "e" {
+			t.Errorf("path %d is not a route from s to e: %v", i+1, path)
+		}
+	}
+
+	// Solving again from scratch searches once per path and once more to
+	// find there are no more; the update searched for the new path alone.
+	incremental := flow.network.search.gen - searches
+	full := NewIncrementalFlow(mustParse(t, gridMap(30, 10, 10), parseOptions{})).network.search.gen
+	if incremental != 2 || full != 11 {
+		t.Errorf("AddLink searched %d times, solving again %d; want 2 and 11", incremental, full)
+	}
+
+	// A tunnel that opens no new route adds no path.
+	if added, err := flow.AddLink("r0_0", "r1_1"); err != nil || added != 0 {
+		t.Errorf("diagonal tunnel: added %d paths, %v; want none", added, err)
+	}
+	if _, err := flow.AddLink("r29_9", "e"); !errors.Is(err, errDuplicateConnection) {
+		t.Errorf("adding the tunnel again: err = %v, want errDuplicateConnection", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonMap is a map in the JSON format, convenient for maps generated by other
+// programs:
+//
+//	{"ants": 3, "start": "a", "end": "c",
+//	 "rooms": [{"name": "a", "x": 0, "y": 0, "tags": {"kind": "nest"}}, ...],
+//	 "links": [["a", "b"], ["b", "c"]]}
+type jsonMap struct {
+	Ants  int         `json:"ants"`
+	Start string      `json:"start"`
+	End   string      `json:"end"`
+	Rooms []jsonRoom  `json:"rooms"`
+	Links [][2]string `json:"links"`
+}
+
+// jsonRoom is a room of a jsonMap.
+type jsonRoom struct {
+	Name string            `json:"name"`
+	X    int               `json:"x"`
+	Y    int               `json:"y"`
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// loadJSON reads a map in the JSON format, holding it to the same rules as a
+// text map.
+func loadJSON(r io.Reader) (*Graph, error) {
+	var doc jsonMap
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON map: %w", err)
+	}
+	if doc.Ants <= 0 {
+		return nil, fmt.Errorf("invalid number of ants: %d", doc.Ants)
+	}
+
+	graph := NewGraph()
+	graph.AntCount = doc.Ants
+	for _, room := range doc.Rooms {
+		if err := validateRoomName(room.Name, 0); err != nil {
+			return nil, err
+		}
+		if err := graph.AddRoom(room.Name, room.X, room.Y, false, false); err != nil {
+			return nil, err
+		}
+		if len(room.Tags) > 0 {
+			tagged := graph.Rooms[room.Name]
+			tagged.Tags = room.Tags
+			graph.Rooms[room.Name] = tagged
+		}
+	}
+	for _, link := range doc.Links {
+		if link[0] == link[1] {
+			return nil, fmt.Errorf("self referencing room: %s", link[0])
+		}
+		if err := graph.AddConnection(link[0], link[1]); err != nil {
+			return nil, err
+		}
+	}
+	if doc.Start == "" || doc.End == "" {
+		return nil, errors.New("missing start or end room")
+	}
+	if err := graph.SetStart(doc.Start); err != nil {
+		return nil, err
+	}
+	if err := graph.SetEnd(doc.End); err != nil {
+		return nil, err
+	}
+
+	if err := graph.Validate(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
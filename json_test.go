@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// toJSONMap returns the JSON form of a graph, with rooms and links in name
+// order.
+func toJSONMap(graph *Graph) jsonMap {
+	doc := jsonMap{Ants: graph.AntCount, Start: graph.StartRoom, End: graph.EndRoom}
+	names := make([]string, 0, len(graph.Rooms))
+	for name := range graph.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		room := graph.Rooms[name]
+		doc.Rooms = append(doc.Rooms, jsonRoom{Name: name, X: room.X, Y: room.Y, Tags: room.Tags})
+		for _, neighbor := range graph.Connections[name] {
+			if name < neighbor {
+				doc.Links = append(doc.Links, [2]string{name, neighbor})
+			}
+		}
+	}
+	return doc
+}
+
+func TestLoadJSON(t *testing.T) {
+	const doc = `{"ants": 3, "start": "a", "end": "c",
+		"rooms": [{"name": "a", "x": 0, "y": 0}, {"name": "b", "x": 1, "y": 2, "tags": {"kind": "nest"}}, {"name": "c", "x": 2, "y": 0}],
+		"links": [["a", "b"], ["b", "c"]]}`
+	graph, err := loadJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustParse(t, "3\n##start\na 0 0\n# kind:nest\nb 1 2\n##end\nc 2 0\na-b\nb-c\n", parseOptions{tags: true})
+	if diff := want.Diff(graph); diff != nil {
+		t.Errorf("loaded graph differs from the text map: %v", diff)
+	}
+
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		data, err := json.Marshal(toJSONMap(graph))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := loadJSON(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if diff := graph.Diff(loaded); diff != nil {
+			t.Errorf("%s changed in a JSON round trip: %v", name, diff)
+		}
+	}
+}
+
+func TestLoadJSONErrors(t *testing.T) {
+	tests := []struct {
+		doc, want string
+	}{
+		{`{"ants": 1, "start": "a"`, "invalid JSON map: unexpected EOF"},
+		{`{"ants": "one"}`, "invalid JSON map: json: cannot unmarshal string"},
+		{`{"ants": 1, "colour": "red"}`, `invalid JSON map: json: unknown field "colour"`},
+		{`{"ants": 0, "start": "a", "end": "b", "rooms": [{"name": "a"}, {"name": "b"}], "links": [["a", "b"]]}`, "invalid number of ants: 0"},
+		{`{"ants": 1, "start": "a", "end": "b", "rooms": [{"name": "a"}, {"name": "b"}], "links": [["a", "a"]]}`, "self referencing room: a"},
+		{`{"ants": 1, "start": "a", "end": "c", "rooms": [{"name": "a"}, {"name": "b"}], "links": [["a", "b"]]}`, "unknown end room: c"},
+		{`{"ants": 1, "rooms": [{"name": "a"}, {"name": "b"}], "links": [["a", "b"]]}`, "missing start or end room"},
+		{`{"ants": 1, "start": "a", "end": "b", "rooms": [{"name": "a"}, {"name": "b"}, {"name": "c"}], "links": [["a", "c"]]}`, "end room has no tunnels: b"},
+	}
+	for _, tt := range tests {
+		_, err := loadJSON(strings.NewReader(tt.doc))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %s", tt.doc, err, tt.want)
+		}
+	}
+}
+
+func FuzzLoadJSON(f *testing.F) {
+	for _, name := range exampleMaps {
+		data, err := json.Marshal(toJSONMap(readExample(f, name)))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		graph, err := loadJSON(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkParsed(t, graph)
+	})
+}
@@ -1,26 +1,67 @@
+// Command lem-in moves a colony of ants from the start room of an ant farm to
+// its end room in as few turns as possible.
+//
+// Usage:
+//
+//	go run . [flags] <input_file>
+//	go run . <solve|validate|visualize|render> [flags] <input_file> [file]
+//
+// The subcommands solve the map, check it (and optionally a file of moves),
+// play a solution over it, or draw it: render writes an SVG image with the
+// rooms at their coordinates to a .svg file, or Graphviz DOT to a .dot file
+// or stdout.
+//
+// The input file may also be an http or https URL to fetch the map from.
+//
+// A link may carry a weight, as in "a-b:3". The solvers prefer cheaper paths
+// and send more of the ants down them, but every tunnel takes one turn to
+// cross.
+//
+// Run with -h to list the available flags.
+//
+// The program exits with status 0 when the map is solved and with status 1 on
+// any usage, parse or solve error. Errors are printed as "ERROR: <reason>".
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Room represents a room in the ant farm.
 type Room struct {
-	Name    string
-	X, Y    int
-	IsStart bool
-	IsEnd   bool
+	Name     string
+	X, Y     int
+	FX, FY   float64 // exact coordinates for rendering; X and Y rounded
+	IsStart  bool
+	IsEnd    bool
+	Capacity int               // how many ants the room holds at once
+	Tags     map[string]string // from "# key:value" comments before the room
 }
 
 // Graph represents the entire ant farm.
 type Graph struct {
 	Rooms       map[string]Room
 	Connections map[string][]string
+	Weights     map[string]map[string]int // ranking cost of each weighted tunnel
+	Widths      map[string]map[string]int // ants each wider tunnel carries per turn
 	AntCount    int
 	StartRoom   string
 	EndRoom     string
@@ -31,21 +72,213 @@ func NewGraph() *Graph {
 	return &Graph{
 		Rooms:       make(map[string]Room),
 		Connections: make(map[string][]string),
+		Weights:     make(map[string]map[string]int),
+		Widths:      make(map[string]map[string]int),
 	}
 }
 
-// AddRoom adds a room to the graph.
-func (g *Graph) AddRoom(name string, x, y int, isStart, isEnd bool) {
-	g.Rooms[name] = Room{Name: name, X: x, Y: y, IsStart: isStart, IsEnd: isEnd}
+// Clone returns a deep copy of the graph that shares no maps or slices with
+// the original, so the two can be used from different goroutines.
+func (g *Graph) Clone() *Graph {
+	clone := NewGraph()
+	clone.AntCount = g.AntCount
+	clone.StartRoom = g.StartRoom
+	clone.EndRoom = g.EndRoom
+	for name, room := range g.Rooms {
+		if room.Tags != nil {
+			room.Tags = maps.Clone(room.Tags)
+		}
+		clone.Rooms[name] = room
+	}
+	for name, neighbors := range g.Connections {
+		clone.Connections[name] = append([]string(nil), neighbors...)
+	}
+	for name, weights := range g.Weights {
+		clone.Weights[name] = make(map[string]int, len(weights))
+		for neighbor, weight := range weights {
+			clone.Weights[name][neighbor] = weight
+		}
+	}
+	for name, widths := range g.Widths {
+		clone.Widths[name] = make(map[string]int, len(widths))
+		for neighbor, width := range widths {
+			clone.Widths[name][neighbor] = width
+		}
+	}
+	return clone
+}
+
+// AddRoom adds a room with the default capacity of one ant to the graph.
+// Adding a room whose name is already taken, or a second start or end room,
+// is an error; SetStart and SetEnd move the start and end instead.
+func (g *Graph) AddRoom(name string, x, y int, isStart, isEnd bool) error {
+	if _, ok := g.Rooms[name]; ok {
+		return fmt.Errorf("duplicate room: %s", name)
+	}
+	if isStart && g.StartRoom != "" {
+		return fmt.Errorf("more than one start room: %s and %s", g.StartRoom, name)
+	}
+	if isEnd && g.EndRoom != "" {
+		return fmt.Errorf("more than one end room: %s and %s", g.EndRoom, name)
+	}
+	g.Rooms[name] = Room{Name: name, X: x, Y: y, FX: float64(x), FY: float64(y), IsStart: isStart, IsEnd: isEnd, Capacity: 1}
 	if isStart {
 		g.StartRoom = name
 	}
 	if isEnd {
 		g.EndRoom = name
 	}
+	return nil
+}
+
+// RemoveRoom removes a room along with every tunnel leading to or from it.
+// Removing the start or end room leaves the graph without one.
+func (g *Graph) RemoveRoom(name string) error {
+	if _, ok := g.Rooms[name]; !ok {
+		return fmt.Errorf("unknown room: %s", name)
+	}
+	// A one-way tunnel into the room is only listed by the room it leaves,
+	// so every room's tunnels are checked.
+	for room := range g.Connections {
+		if room != name && (g.Connected(room, name) || g.Connected(name, room)) {
+			if err := g.RemoveLink(room, name); err != nil {
+				return err
+			}
+		}
+	}
+	delete(g.Rooms, name)
+	delete(g.Connections, name)
+	delete(g.Weights, name)
+	delete(g.Widths, name)
+	if g.StartRoom == name {
+		g.StartRoom = ""
+	}
+	if g.EndRoom == name {
+		g.EndRoom = ""
+	}
+	return nil
+}
+
+// Validate reports whether the graph can be solved at all: it needs a start
+// room and a different end room, each with at least one tunnel.
+func (g *Graph) Validate() error {
+	if g.StartRoom == "" || g.EndRoom == "" {
+		return errors.New("missing start or end room")
+	}
+	if g.StartRoom == g.EndRoom {
+		return fmt.Errorf("start and end are the same room: %s", g.StartRoom)
+	}
+	// An isolated start or end rules out every path, so there is no point
+	// searching for one.
+	if g.Degree(g.StartRoom) == 0 {
+		return fmt.Errorf("start room has no tunnels: %s", g.StartRoom)
+	}
+	if !g.entered(g.EndRoom) {
+		return fmt.Errorf("end room has no tunnels: %s", g.EndRoom)
+	}
+	return nil
 }
 
-// AddConnection adds a connection (tunnel) between two rooms.
+// entered reports whether any tunnel leads into room. Only one-way tunnels
+// make this differ from room having tunnels of its own.
+func (g *Graph) entered(room string) bool {
+	for name := range g.Connections {
+		if g.Connected(name, room) {
+			return true
+		}
+	}
+	return false
+}
+
+// Connected reports whether a tunnel leads from roomA to roomB.
+func (g *Graph) Connected(roomA, roomB string) bool {
+	for _, neighbor := range g.Connections[roomA] {
+		if neighbor == roomB {
+			return true
+		}
+	}
+	return false
+}
+
+// OneWay reports whether a tunnel leads from roomA to roomB but not back.
+func (g *Graph) OneWay(roomA, roomB string) bool {
+	return g.Connected(roomA, roomB) && !g.Connected(roomB, roomA)
+}
+
+// lists reports whether the tunnel from roomA to roomB is the one to write
+// when walking the tunnels of every room: a two-way tunnel appears from both
+// ends but is written once, from the room whose name sorts first.
+func (g *Graph) lists(roomA, roomB string) bool {
+	return roomA < roomB || g.OneWay(roomA, roomB)
+}
+
+// Degree returns the number of tunnels leading out of room.
+func (g *Graph) Degree(room string) int {
+	return len(g.Connections[room])
+}
+
+// SetStart designates an existing room as the start room, replacing any
+// previous start.
+func (g *Graph) SetStart(name string) error {
+	room, ok := g.Rooms[name]
+	if !ok {
+		return fmt.Errorf("unknown start room: %s", name)
+	}
+	if previous, ok := g.Rooms[g.StartRoom]; ok {
+		previous.IsStart = false
+		g.Rooms[g.StartRoom] = previous
+		room = g.Rooms[name]
+	}
+	room.IsStart = true
+	g.Rooms[name] = room
+	g.StartRoom = name
+	return nil
+}
+
+// SetEnd designates an existing room as the end room, replacing any previous
+// end.
+func (g *Graph) SetEnd(name string) error {
+	room, ok := g.Rooms[name]
+	if !ok {
+		return fmt.Errorf("unknown end room: %s", name)
+	}
+	if previous, ok := g.Rooms[g.EndRoom]; ok {
+		previous.IsEnd = false
+		g.Rooms[g.EndRoom] = previous
+		room = g.Rooms[name]
+	}
+	room.IsEnd = true
+	g.Rooms[name] = room
+	g.EndRoom = name
+	return nil
+}
+
+// Unlimited reports whether a room holds any number of ants at once, which
+// the start and end rooms do whatever capacity they were given.
+func (g *Graph) Unlimited(room string) bool {
+	return room == g.StartRoom || room == g.EndRoom
+}
+
+// SetCapacity changes how many ants a room can hold at once. The capacity of
+// the start and end rooms is ignored; see Unlimited.
+func (g *Graph) SetCapacity(name string, capacity int) error {
+	room, ok := g.Rooms[name]
+	if !ok {
+		return fmt.Errorf("unknown room: %s", name)
+	}
+	if capacity < 1 {
+		return fmt.Errorf("invalid capacity for room %s: %d", name, capacity)
+	}
+	room.Capacity = capacity
+	g.Rooms[name] = room
+	return nil
+}
+
+// errDuplicateConnection is returned when a tunnel is added twice.
+var errDuplicateConnection = errors.New("identical connection already exists")
+
+// AddConnection adds a connection (tunnel) between two rooms. Adding a tunnel
+// that already exists, in either direction, is an error.
 func (g *Graph) AddConnection(roomA, roomB string) error {
 	if _, ok := g.Rooms[roomA]; !ok {
 		return fmt.Errorf("invalid connection: %s - %s", roomA, roomB)
@@ -53,151 +286,831 @@ func (g *Graph) AddConnection(roomA, roomB string) error {
 	if _, ok := g.Rooms[roomB]; !ok {
 		return fmt.Errorf("invalid connection: %s - %s", roomA, roomB)
 	}
+	if g.Connected(roomA, roomB) || g.Connected(roomB, roomA) {
+		return fmt.Errorf("%w: %s - %s", errDuplicateConnection, roomA, roomB)
+	}
 	g.Connections[roomA] = append(g.Connections[roomA], roomB)
 	g.Connections[roomB] = append(g.Connections[roomB], roomA)
 	return nil
 }
 
-// readInput parses the input file and constructs the graph.
-func readInput(filename string) (*Graph, string, string, int) {
-	file, err := os.Open(filename)
+// AddOneWayConnection adds a tunnel that leads from one room to another but
+// not back. A tunnel back may be added on its own.
+func (g *Graph) AddOneWayConnection(from, to string) error {
+	if _, ok := g.Rooms[from]; !ok {
+		return fmt.Errorf("invalid connection: %s -> %s", from, to)
+	}
+	if _, ok := g.Rooms[to]; !ok {
+		return fmt.Errorf("invalid connection: %s -> %s", from, to)
+	}
+	if g.Connected(from, to) {
+		return fmt.Errorf("%w: %s -> %s", errDuplicateConnection, from, to)
+	}
+	g.Connections[from] = append(g.Connections[from], to)
+	return nil
+}
+
+// RemoveLink removes the tunnel between two rooms, along with its weight and
+// width.
+func (g *Graph) RemoveLink(roomA, roomB string) error {
+	if !g.Connected(roomA, roomB) && !g.Connected(roomB, roomA) {
+		return fmt.Errorf("no connection: %s - %s", roomA, roomB)
+	}
+	g.Connections[roomA] = slices.DeleteFunc(g.Connections[roomA], func(room string) bool { return room == roomB })
+	g.Connections[roomB] = slices.DeleteFunc(g.Connections[roomB], func(room string) bool { return room == roomA })
+	delete(g.Weights[roomA], roomB)
+	delete(g.Weights[roomB], roomA)
+	delete(g.Widths[roomA], roomB)
+	delete(g.Widths[roomB], roomA)
+	return nil
+}
+
+// SetWeight sets the cost of an existing tunnel. Weights only rank paths,
+// cheapest first: an ant still crosses any tunnel in a single turn.
+func (g *Graph) SetWeight(roomA, roomB string, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("invalid weight for connection %s - %s: %d", roomA, roomB, weight)
+	}
+	if g.Weights[roomA] == nil {
+		g.Weights[roomA] = make(map[string]int)
+	}
+	if g.Weights[roomB] == nil {
+		g.Weights[roomB] = make(map[string]int)
+	}
+	g.Weights[roomA][roomB] = weight
+	g.Weights[roomB][roomA] = weight
+	return nil
+}
+
+// Weight returns the cost of the tunnel between two rooms.
+// Tunnels without an explicit weight cost 1.
+func (g *Graph) Weight(roomA, roomB string) int {
+	if weight, ok := g.Weights[roomA][roomB]; ok {
+		return weight
+	}
+	return 1
+}
+
+// SetWidth sets how many ants may cross an existing tunnel in one turn.
+func (g *Graph) SetWidth(roomA, roomB string, width int) error {
+	if width < 1 {
+		return fmt.Errorf("invalid width for connection %s - %s: %d", roomA, roomB, width)
+	}
+	if g.Widths[roomA] == nil {
+		g.Widths[roomA] = make(map[string]int)
+	}
+	if g.Widths[roomB] == nil {
+		g.Widths[roomB] = make(map[string]int)
+	}
+	g.Widths[roomA][roomB] = width
+	g.Widths[roomB][roomA] = width
+	return nil
+}
+
+// Width returns how many ants may cross the tunnel between two rooms in one
+// turn. Tunnels without an explicit width carry one ant.
+func (g *Graph) Width(roomA, roomB string) int {
+	if width, ok := g.Widths[roomA][roomB]; ok {
+		return width
+	}
+	return 1
+}
+
+// gzipMagic is the header that starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// parseOptions adjusts how strictly maps are parsed.
+type parseOptions struct {
+	// lenient collapses duplicate links into a single tunnel instead of
+	// rejecting the map.
+	lenient bool
+	// start and end, when set, name the start and end rooms, overriding
+	// any ##start and ##end commands in the map.
+	start, end string
+	// maxNameLength, when positive, limits room names to that many
+	// characters.
+	maxNameLength int
+	// floatCoords accepts fractional coordinates, which only renderers use
+	// exactly; X and Y hold them rounded.
+	floatCoords bool
+	// infer lists the rooms that could be the start or end room when the
+	// map does not designate them.
+	infer bool
+	// tags collects "# key:value" comments into the tags of the room they
+	// precede.
+	tags bool
+}
+
+// parseTag splits a "# key:value" comment into its key and value. The key is
+// a single word; comments of any other form are not tags.
+func parseTag(line string) (key, value string, ok bool) {
+	text, ok := strings.CutPrefix(line, "#")
+	if !ok || strings.HasPrefix(text, "#") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(strings.TrimSpace(text), ":")
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// validateRoomName checks a room name against the format rules: a name may
+// not start with 'L' or '#', and, when maxLength is positive, may have at most
+// maxLength characters. Names are checked as UTF-8 text, so multibyte
+// characters count once.
+func validateRoomName(name string, maxLength int) error {
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("invalid room name: %q", name)
+	}
+	if first, _ := utf8.DecodeRuneInString(name); first == 'L' || first == '#' {
+		return fmt.Errorf("invalid room name: %s", name)
+	}
+	if maxLength > 0 && utf8.RuneCountInString(name) > maxLength {
+		return fmt.Errorf("room name longer than %d characters: %.20s", maxLength, name)
+	}
+	return nil
+}
+
+// textFormatVersion is the format version of the classic text format, which
+// maps may declare with a "#format 1" first line.
+const textFormatVersion = 1
+
+// checkFormatVersion reports an error unless version names a text format
+// version this parser understands.
+func checkFormatVersion(version string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(version))
 	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(0)
+		return fmt.Errorf("invalid format version: %s", version)
+	}
+	if n != textFormatVersion {
+		return fmt.Errorf("unsupported format version: %d", n)
+	}
+	return nil
+}
+
+// readInput opens the input file and constructs the graph from it, returning
+// the raw lines of a text map as parseMap does. Files ending in .gz or
+// starting with a gzip header are decompressed on the fly. Maps starting with
+// binaryMagic are read in the binary format and maps starting with "{" in the
+// JSON format; neither has lines.
+func readInput(filename string, opts parseOptions) (*Graph, []string, error) {
+	r, closeInput, err := openInput(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeInput()
+
+	if header, _ := r.Peek(len(binaryMagic)); bytes.Equal(header, binaryMagic) {
+		graph, err := loadBinary(r)
+		return graph, nil, err
+	}
+	if header, _ := r.Peek(1); bytes.Equal(header, []byte("{")) {
+		graph, err := loadJSON(r)
+		return graph, nil, err
+	}
+	return parseMap(r, opts)
+}
+
+const (
+	// maxURLBytes limits the size of a map fetched from a URL.
+	maxURLBytes = 64 << 20
+	// fetchTimeout limits how long fetching a map from a URL may take.
+	fetchTimeout = 30 * time.Second
+)
+
+// openInput opens the input file, or fetches it if filename is an http or
+// https URL, decompressing it if it is gzipped. The returned function closes
+// it.
+func openInput(filename string) (*bufio.Reader, func(), error) {
+	var file io.ReadCloser
+	var err error
+	if strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		file, err = fetch(filename)
+	} else {
+		file, err = os.Open(filename)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	header, _ := buffered.Peek(len(gzipMagic))
+	if strings.HasSuffix(filename, ".gz") || bytes.Equal(header, gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return bufio.NewReader(gz), func() { gz.Close(); file.Close() }, nil
+	}
+	return buffered, func() { file.Close() }, nil
+}
+
+// maxLineLength bounds the length of the lines read from maps and move
+// files. A turn of moves for many thousands of ants can run to hundreds of
+// kilobytes, well past bufio.Scanner's default limit.
+const maxLineLength = 16 << 20
+
+// newLineScanner returns a scanner over the lines of r that accepts lines of
+// up to maxLineLength bytes.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	return scanner
+}
+
+// scanError explains an error from a line scanner that stopped after line
+// lines, naming the line that was too long.
+func scanError(err error, line int) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("line %d is longer than %d bytes", line+1, maxLineLength)
+	}
+	return err
+}
+
+// fetch requests url and returns the response body, which fails to read
+// past maxURLBytes. The whole request, reading included, must finish within
+// fetchTimeout.
+func fetch(url string) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", url, response.Status)
+	}
+	return http.MaxBytesReader(nil, response.Body, maxURLBytes), nil
+}
+
+// mapSeparator is the line that separates the maps in a file read with
+// readMaps.
+const mapSeparator = "===="
+
+// readMaps reads a text file holding several maps separated by mapSeparator
+// lines, parsing each map on its own. It returns the graphs with the raw
+// lines of each map.
+func readMaps(filename string, opts parseOptions) ([]*Graph, [][]string, error) {
+	r, closeInput, err := openInput(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeInput()
+
+	var sections []string
+	var section strings.Builder
+	scanner := newLineScanner(r)
+	read := 0
+	for scanner.Scan() {
+		read++
+		if strings.TrimSpace(scanner.Text()) == mapSeparator {
+			sections = append(sections, section.String())
+			section.Reset()
+			continue
+		}
+		section.WriteString(scanner.Text())
+		section.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, scanError(err, read)
+	}
+	sections = append(sections, section.String())
+
+	graphs := make([]*Graph, len(sections))
+	lines := make([][]string, len(sections))
+	for i, text := range sections {
+		graphs[i], lines[i], err = parseMap(strings.NewReader(text), opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("map %d: %w", i+1, err)
+		}
+	}
+	return graphs, lines, nil
+}
+
+// pendingCommand names the command waiting for its room, for errors.
+func pendingCommand(start bool) string {
+	if start {
+		return "##start"
 	}
-	defer file.Close()
+	return "##end"
+}
 
+// parseCoordinate parses a coordinate written as a plain decimal integer:
+// an optional '-' and digits without leading zeros. Unlike strconv.Atoi it
+// rejects forms such as "+2" and "01".
+func parseCoordinate(text string) (int, error) {
+	digits := strings.TrimPrefix(text, "-")
+	if digits == "" || (digits[0] == '0' && text != "0") {
+		return 0, fmt.Errorf("invalid coordinate: %s", text)
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid coordinate: %s", text)
+		}
+	}
+	return strconv.Atoi(text)
+}
+
+// maxFloatCoordinate bounds float coordinates so that they round to an int.
+const maxFloatCoordinate = 1e15
+
+// parseRoomCoordinate parses a coordinate with parseCoordinate, or, when
+// floats is set, as any finite decimal number. It returns the coordinate
+// rounded to an integer along with its exact value.
+func parseRoomCoordinate(text string, floats bool) (int, float64, error) {
+	n, err := parseCoordinate(text)
+	if err == nil || !floats {
+		return n, float64(n), err
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(f) || math.Abs(f) > maxFloatCoordinate {
+		return 0, 0, fmt.Errorf("invalid coordinate: %s", text)
+	}
+	return int(math.Round(f)), f, nil
+}
+
+// parseLink adds the tunnel described by a link line such as "a-b" or, with
+// a weight, "a-b:3". Weights rank the paths the solvers consider and decide
+// how the ants are spread over them, but the ants still cross every tunnel in
+// one turn. A width, "a-b*2", lets
+// that many ants cross the tunnel in one turn; it comes before any weight, as
+// in "a-b*2:3". A tunnel written "a->b" leads one way, from a to b.
+func parseLink(graph *Graph, line string, opts parseOptions) error {
+	link, weightStr, weighted := strings.Cut(line, ":")
+	link, widthStr, wide := strings.Cut(link, "*")
+	separator, connect := "-", graph.AddConnection
+	if strings.Contains(link, "->") {
+		separator, connect = "->", graph.AddOneWayConnection
+	}
+	parts := strings.Split(link, separator)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid connection: %q", line)
+	}
+	if parts[0] == parts[1] {
+		return fmt.Errorf("self referencing room: %q", line)
+	}
+	if err := connect(parts[0], parts[1]); err != nil {
+		if !errors.Is(err, errDuplicateConnection) {
+			return fmt.Errorf("invalid connection: %q", line)
+		}
+		if opts.lenient {
+			return nil
+		}
+		return fmt.Errorf("identical connection already exists: %q", line)
+	}
+	if weighted {
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || graph.SetWeight(parts[0], parts[1], weight) != nil {
+			return fmt.Errorf("invalid connection weight: %q", line)
+		}
+	}
+	if wide {
+		width, err := strconv.Atoi(widthStr)
+		if err != nil || graph.SetWidth(parts[0], parts[1], width) != nil {
+			return fmt.Errorf("invalid connection width: %q", line)
+		}
+	}
+	return nil
+}
+
+// ParseMap parses a map from r with the default parse options. Along with the
+// graph it returns the lines of the map as they were read, so that callers
+// can echo the map.
+func ParseMap(r io.Reader) (*Graph, []string, error) {
+	return parseMap(r, parseOptions{})
+}
+
+// parseMap parses a map from r and constructs the graph, returning it with
+// the raw lines of the map.
+func parseMap(r io.Reader, opts parseOptions) (*Graph, []string, error) {
+	var err error
 	graph := NewGraph()
-	scanner := bufio.NewScanner(file)
+	scanner := newLineScanner(r)
 	lineNumber := 0
-	var start, end bool
+	// Set by ##start and ##end, these mark the next room defined. Only these
+	// commands designate the start and end; room names never do.
+	var markStart, markEnd bool
+	// Collected under -tags, these belong to the next room defined.
+	var tags map[string]string
+	var links []string
+	empty := true
+
+	var raw []string
 
 	for scanner.Scan() {
-		line := scanner.Text()
+		raw = append(raw, scanner.Text())
+		// Trim surrounding whitespace, including the \r of CRLF line endings.
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if empty {
+			// An optional "#format N" first line declares the format version.
+			if version, ok := strings.CutPrefix(line, "#format "); ok {
+				if err := checkFormatVersion(version); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		empty = false
 		if strings.HasPrefix(line, "#") {
 			if line == "##start" {
-				start = true
+				markStart = true
 			} else if line == "##end" {
-				end = true
+				markEnd = true
+			} else if key, value, ok := parseTag(line); ok && opts.tags {
+				if tags == nil {
+					tags = make(map[string]string)
+				}
+				tags[key] = value
 			}
 			continue
 		}
 
+		// The first line that is neither blank nor a comment is the ant count.
 		if lineNumber == 0 {
 			graph.AntCount, err = strconv.Atoi(line)
-			if err != nil || graph.AntCount == 0 {
-				fmt.Println("ERROR: invalid number of ants")
-				os.Exit(0)
+			// A room or link in place of the count means it was left out.
+			if err != nil && (len(strings.Fields(line)) > 1 || strings.Contains(line, "-")) {
+				return nil, nil, fmt.Errorf("missing number of ants: map starts with %q", line)
+			}
+			if err != nil || graph.AntCount <= 0 {
+				return nil, nil, fmt.Errorf("invalid number of ants")
 			}
 			lineNumber++
+			tags = nil
 			continue
 		}
 
-		if strings.Contains(line, "-") {
-			parts := strings.Split(line, "-")
-			if len(parts) != 2 {
-				fmt.Println("ERROR: invalid connection:", line)
-				os.Exit(0)
-			}
-			if parts[0] == parts[1] {
-				fmt.Println("ERROR: self referencing room:", line)
-				os.Exit(0)
+		fields := strings.Fields(line)
+		// A room line may hold a '-' in a negative coordinate, so only lines
+		// that don't have a room's fields are links.
+		if strings.Contains(line, "-") && len(fields) != 3 && len(fields) != 4 {
+			// Comments may come between ##start or ##end and its room, but
+			// a link may not.
+			if markStart || markEnd {
+				return nil, nil, fmt.Errorf("%s must be followed by a room, not a link: %q", pendingCommand(markStart), line)
 			}
-			for key, vals := range graph.Connections {
-				for _, val := range vals {
-					if (key == parts[0] && val == parts[1]) || (key == parts[1] && val == parts[0]) {
-						fmt.Println("ERROR: identical connection already exists:", line)
-						os.Exit(0)
-					}
-				}
-			}
-			graph.AddConnection(parts[0], parts[1])
+			// Links are added once every room is known, so they may appear
+			// before the rooms they join.
+			links = append(links, line)
+			tags = nil
 		} else {
-			fields := strings.Fields(line)
-			if len(fields) != 3 {
-				fmt.Println("ERROR: invalid room format:", line)
-				os.Exit(0)
+			if len(fields) != 3 && len(fields) != 4 {
+				return nil, nil, fmt.Errorf("invalid room format: %q", line)
 			}
 			name, xStr, yStr := fields[0], fields[1], fields[2]
-			x, err := strconv.Atoi(xStr)
+			if err := validateRoomName(name, opts.maxNameLength); err != nil {
+				return nil, nil, err
+			}
+			x, fx, err := parseRoomCoordinate(xStr, opts.floatCoords)
 			if err != nil {
-				fmt.Println("ERROR: invalid x coordinate")
-				os.Exit(0)
+				return nil, nil, fmt.Errorf("invalid x coordinate: %q", xStr)
 			}
-			y, err := strconv.Atoi(yStr)
+			y, fy, err := parseRoomCoordinate(yStr, opts.floatCoords)
 			if err != nil {
-				fmt.Println("ERROR: invalid y coordinate")
-				os.Exit(0)
+				return nil, nil, fmt.Errorf("invalid y coordinate: %q", yStr)
+			}
+			if err := graph.AddRoom(name, x, y, markStart, markEnd); err != nil {
+				return nil, nil, err
+			}
+			if opts.floatCoords {
+				room := graph.Rooms[name]
+				room.FX, room.FY = fx, fy
+				graph.Rooms[name] = room
+			}
+			if len(fields) == 4 {
+				capacity, err := strconv.Atoi(fields[3])
+				if err != nil || graph.SetCapacity(name, capacity) != nil {
+					return nil, nil, fmt.Errorf("invalid room capacity: %q", line)
+				}
 			}
-			graph.AddRoom(name, x, y, start, end)
-			start, end = false, false
+			if tags != nil {
+				room := graph.Rooms[name]
+				room.Tags = tags
+				graph.Rooms[name] = room
+				tags = nil
+			}
+			markStart, markEnd = false, false
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(0)
+		return nil, nil, scanError(err, len(raw))
+	}
+	if empty {
+		return nil, nil, errors.New("empty input")
+	}
+	if markStart || markEnd {
+		return nil, nil, fmt.Errorf("%s is not followed by a room", pendingCommand(markStart))
+	}
+	for _, line := range links {
+		if err := parseLink(graph, line, opts); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.start != "" {
+		if err := graph.SetStart(opts.start); err != nil {
+			return nil, nil, err
+		}
 	}
-	if graph.StartRoom == "" || graph.EndRoom == "" {
-		fmt.Println("ERROR: missing start or end room")
-		os.Exit(0)
+	if opts.end != "" {
+		if err := graph.SetEnd(opts.end); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := graph.Validate(); err != nil {
+		if opts.infer && (graph.StartRoom == "" || graph.EndRoom == "") {
+			candidates := endpointCandidates(graph)
+			if len(candidates) == 0 {
+				return nil, nil, fmt.Errorf("%w; no room has a single tunnel", err)
+			}
+			return nil, nil, fmt.Errorf("%w; candidates: %s", err, strings.Join(candidates, ", "))
+		}
+		return nil, nil, err
 	}
-	return graph, graph.StartRoom, graph.EndRoom, graph.AntCount
+	return graph, raw, nil
 }
 
 // findAllPaths uses DFS to find all paths from the start room to the end room.
-func findAllPaths(graph *Graph, currentRoom string, visited map[string]bool, path []string, allPaths *[][]string) {
-	visited[currentRoom] = true
-	path = append(path, currentRoom)
-
-	if currentRoom == graph.EndRoom {
-		pathCopy := make([]string, len(path))
-		copy(pathCopy, path)
-		*allPaths = append(*allPaths, pathCopy)
-	} else {
-		for _, neighbor := range graph.Connections[currentRoom] {
-			if !visited[neighbor] {
-				findAllPaths(graph, neighbor, visited, path, allPaths)
+// When maxRooms is positive, paths with more rooms than that are not explored.
+// The search keeps its own stack rather than recursing, so a path may be as
+// long as memory allows.
+func findAllPaths(graph *Graph, currentRoom string, visited map[string]bool, path []string, allPaths *[][]string, maxRooms int) {
+	// frame is a room on the current path and the index of the next
+	// neighbor to explore from it.
+	type frame struct {
+		room string
+		next int
+	}
+
+	var stack []frame
+	// enter extends the path by room. The end room completes a path, which
+	// is recorded at once, so it is never explored past.
+	enter := func(room string) {
+		if room == graph.EndRoom {
+			*allPaths = append(*allPaths, append(slices.Clone(path), room))
+			return
+		}
+		visited[room] = true
+		path = append(path, room)
+		stack = append(stack, frame{room: room})
+	}
+
+	enter(currentRoom)
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		neighbors := graph.Connections[top.room]
+		if top.next < len(neighbors) {
+			neighbor := neighbors[top.next]
+			top.next++
+			if !visited[neighbor] && (maxRooms <= 0 || len(path) < maxRooms) {
+				enter(neighbor)
 			}
+			continue
 		}
+
+		// Backtracking
+		visited[top.room] = false
+		path = path[:len(path)-1]
+		stack = stack[:len(stack)-1]
 	}
+}
+
+// pathNode is a partial path found by findAllPathsBFS: its last room and the
+// node of the path it extends.
+type pathNode struct {
+	room   string
+	parent *pathNode
+	depth  int // rooms on the path
+}
 
-	// Backtracking
-	path = path[:len(path)-1]
-	visited[currentRoom] = false
+// nodeDepth returns the rooms on the path of node, 0 for nil.
+func nodeDepth(node *pathNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.depth
 }
 
-// findShortestPaths finds the shortest paths using BFS.
-func findShortestPaths(graph *Graph, start string) [][]string {
+// rooms returns the rooms on the path of node, from the start.
+func (n *pathNode) rooms() []string {
+	path := make([]string, n.depth)
+	for node := n; node != nil; node = node.parent {
+		path[node.depth-1] = node.room
+	}
+	return path
+}
+
+// findAllPathsBFS finds the same paths as findAllPaths, but extends every
+// partial path one room at a time, so shorter paths are found first. Partial
+// paths share their rooms through parent links rather than each holding a
+// copy. The rooms of the path being extended are kept in a set, updated by
+// walking from the previous path to the next through their common prefix;
+// consecutive paths mostly differ in their last few rooms, so this is cheap.
+func findAllPathsBFS(graph *Graph, start string, maxRooms int) [][]string {
 	var allPaths [][]string
-	visited := make(map[string]bool)
-	findAllPaths(graph, start, visited, []string{}, &allPaths)
+	onPath := make(map[string]bool)
+	var marked *pathNode // the path whose rooms are in onPath
+	var entering []*pathNode
+	queue := []*pathNode{{room: start, depth: 1}}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+		if node.room == graph.EndRoom {
+			allPaths = append(allPaths, node.rooms())
+			continue
+		}
+		if maxRooms > 0 && node.depth >= maxRooms {
+			continue
+		}
+
+		// Move onPath from the marked path to this one.
+		from, to := marked, node
+		entering = entering[:0]
+		for nodeDepth(from) > nodeDepth(to) {
+			delete(onPath, from.room)
+			from = from.parent
+		}
+		for nodeDepth(to) > nodeDepth(from) {
+			entering = append(entering, to)
+			to = to.parent
+		}
+		for from != to {
+			delete(onPath, from.room)
+			from = from.parent
+			entering = append(entering, to)
+			to = to.parent
+		}
+		for _, entered := range entering {
+			onPath[entered.room] = true
+		}
+		marked = node
 
-	// Sort paths by length (shortest first)
+		for _, neighbor := range graph.Connections[node.room] {
+			if !onPath[neighbor] {
+				queue = append(queue, &pathNode{room: neighbor, parent: node, depth: node.depth + 1})
+			}
+		}
+	}
+	return allPaths
+}
+
+// pathSearches names the algorithms findShortestPaths can enumerate the paths
+// with.
+var pathSearches = []string{"dfs", "bfs"}
+
+// findShortestPaths finds the paths from start to the end room, cheapest
+// first, enumerating them with the named search: "dfs" (the default) or
+// "bfs". When maxRooms is positive, longer paths are discarded. When workers
+// is above one, a depth-first search runs in up to that many goroutines.
+func findShortestPaths(graph *Graph, start, search string, maxRooms, workers int) [][]string {
+	var allPaths [][]string
+	if search == "bfs" {
+		allPaths = findAllPathsBFS(graph, start, maxRooms)
+	} else if workers > 1 {
+		allPaths = findAllPathsParallel(graph, start, maxRooms, workers)
+	} else {
+		visited := make(map[string]bool)
+		findAllPaths(graph, start, visited, []string{}, &allPaths, maxRooms)
+	}
+
+	// Sort paths by cost (cheapest first). Without weights this is the length.
+	// Paths of equal cost are ordered by their room names so that grouping
+	// sees the same order on every run.
 	sort.Slice(allPaths, func(i, j int) bool {
-		return len(allPaths[i]) < len(allPaths[j])
+		costI, costJ := pathCost(graph, allPaths[i]), pathCost(graph, allPaths[j])
+		if costI != costJ {
+			return costI < costJ
+		}
+		return slices.Compare(allPaths[i], allPaths[j]) < 0
 	})
 
 	return allPaths
 }
 
-func solutionsCompatible(sol1, sol2 []string, start, end string) bool {
-	for _, room1 := range sol1 {
-		if room1 == start || room1 == end {
+// pathCost returns the total weight of the tunnels along a path.
+func pathCost(graph *Graph, path []string) int {
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		cost += graph.Weight(path[i-1], path[i])
+	}
+	return cost
+}
+
+// roomQueue is a min-heap of rooms ordered by their distance from the start.
+type roomQueue []roomDistance
+
+type roomDistance struct {
+	Room     string
+	Distance int
+}
+
+func (q roomQueue) Len() int           { return len(q) }
+func (q roomQueue) Less(i, j int) bool { return q[i].Distance < q[j].Distance }
+func (q roomQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *roomQueue) Push(x any)        { *q = append(*q, x.(roomDistance)) }
+func (q *roomQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// findShortestPath uses Dijkstra's algorithm to find the cheapest path from
+// the start room to the end room. It returns nil when the end is unreachable.
+func findShortestPath(graph *Graph) []string {
+	distance := map[string]int{graph.StartRoom: 0}
+	previous := make(map[string]string)
+	queue := &roomQueue{{Room: graph.StartRoom}}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(roomDistance)
+		if current.Distance > distance[current.Room] {
 			continue
 		}
-		for _, room2 := range sol2 {
-			if room1 == room2 {
-				return false
+		if current.Room == graph.EndRoom {
+			break
+		}
+		for _, neighbor := range graph.Connections[current.Room] {
+			next := current.Distance + graph.Weight(current.Room, neighbor)
+			if known, ok := distance[neighbor]; !ok || next < known {
+				distance[neighbor] = next
+				previous[neighbor] = current.Room
+				heap.Push(queue, roomDistance{Room: neighbor, Distance: next})
+			}
+		}
+	}
+
+	if _, ok := distance[graph.EndRoom]; !ok {
+		return nil
+	}
+	path := []string{graph.EndRoom}
+	for room := graph.EndRoom; room != graph.StartRoom; {
+		room = previous[room]
+		path = append([]string{room}, path...)
+	}
+	return path
+}
+
+// intermediateRooms returns the set of rooms a path visits between the start
+// and end rooms.
+func intermediateRooms(path []string, start, end string) map[string]bool {
+	rooms := make(map[string]bool, len(path))
+	for _, room := range path {
+		if room != start && room != end {
+			rooms[room] = true
+		}
+	}
+	return rooms
+}
+
+// dedupePaths removes the paths that can never improve a group: those that
+// pass through every intermediate room of a path that costs no more. Any group
+// using such a path could use the cheaper one instead, which clashes with no
+// more paths. A direct link from start to end has no intermediate rooms but
+// can join any group, so it removes nothing. The paths must be sorted
+// cheapest first, as findShortestPaths returns them.
+func dedupePaths(graph *Graph, paths [][]string) [][]string {
+	var kept [][]string
+	var keptRooms []map[string]bool
+	for _, path := range paths {
+		rooms := intermediateRooms(path, graph.StartRoom, graph.EndRoom)
+		dominated := false
+		for i, other := range keptRooms {
+			if len(other) > 0 && len(other) <= len(rooms) && pathCost(graph, kept[i]) <= pathCost(graph, path) && subsetOf(other, rooms) {
+				dominated = true
+				break
 			}
 		}
+		if !dominated {
+			kept = append(kept, path)
+			keptRooms = append(keptRooms, rooms)
+		}
+	}
+	return kept
+}
+
+// subsetOf reports whether every room of a is also in b.
+func subsetOf(a, b map[string]bool) bool {
+	for room := range a {
+		if !b[room] {
+			return false
+		}
 	}
 	return true
 }
 
-func solutionCompatibleWithGroup(candidate []string, group [][]string, start, end string) bool {
-	for _, sol := range group {
-		if !solutionsCompatible(sol, candidate, start, end) {
+// roomSetsDisjoint reports whether two sets of rooms have no room in common.
+func roomSetsDisjoint(a, b map[string]bool) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for room := range a {
+		if b[room] {
 			return false
 		}
 	}
@@ -207,6 +1120,11 @@ func solutionCompatibleWithGroup(candidate []string, group [][]string, start, en
 func calculateSolutionGroups(solutions [][]string, start, end string) [][][]string {
 	var solGroups [][][]string
 
+	// An empty path cannot carry ants, so it never joins a group.
+	solutions = slices.DeleteFunc(slices.Clone(solutions), func(sol []string) bool {
+		return len(sol) == 0
+	})
+
 	if len(solutions) <= 1 {
 		if len(solutions) == 1 {
 			solGroups = append(solGroups, solutions)
@@ -214,14 +1132,28 @@ func calculateSolutionGroups(solutions [][]string, start, end string) [][][]stri
 		return solGroups
 	}
 
+	// Compute each path's intermediate rooms once so that checking two paths
+	// for compatibility is a set lookup rather than a scan of both paths.
+	roomSets := make([]map[string]bool, len(solutions))
+	for i, sol := range solutions {
+		roomSets[i] = intermediateRooms(sol, start, end)
+	}
+
 	for i, sol1 := range solutions {
 		group := [][]string{sol1}
+		used := make(map[string]bool, len(roomSets[i]))
+		for room := range roomSets[i] {
+			used[room] = true
+		}
 		for j, sol2 := range solutions {
 			if i == j {
 				continue
 			}
-			if solutionCompatibleWithGroup(sol2, group, start, end) {
+			if roomSetsDisjoint(used, roomSets[j]) {
 				group = append(group, sol2)
+				for room := range roomSets[j] {
+					used[room] = true
+				}
 			}
 		}
 		solGroups = append(solGroups, group)
@@ -230,11 +1162,116 @@ func calculateSolutionGroups(solutions [][]string, start, end string) [][][]stri
 	return solGroups
 }
 
-func distributeAnts(paths [][]string, ants int) map[int][]string {
+// estimateTurns returns how many turns the ants need when distributeAnts
+// spreads them across the paths of a group. A path of n rooms carrying k ants
+// finishes after n+k-2 turns, since one ant enters it per turn.
+func estimateTurns(group [][]string, ants int) int {
+	loads := make([]int, len(group))
+	for i, path := range group {
+		loads[i] = len(path)
+	}
+	for ant := 0; ant < ants; ant++ {
+		minIndex := 0
+		for i, load := range loads {
+			if load < loads[minIndex] {
+				minIndex = i
+			}
+		}
+		loads[minIndex]++
+	}
+
+	turns := 0
+	for i, load := range loads {
+		if load > len(group[i]) && load-2 > turns {
+			turns = load - 2
+		}
+	}
+	return turns
+}
+
+// selectBestGroup picks the group that moves the ants in the fewest turns.
+// Each group from calculateSolutionGroups is built greedily around a different
+// anchor path, so no single anchor is guaranteed to give the largest set of
+// disjoint paths; comparing all of them avoids depending on path order. Ties
+// go to the group with more paths, then to the earlier group.
+func selectBestGroup(groups [][][]string, ants, maxPaths int) [][]string {
+	var best [][]string
+	bestTurns := 0
+	for _, group := range groups {
+		if maxPaths > 0 && len(group) > maxPaths {
+			// Groups list their anchor path first, so sort them to keep
+			// the shortest.
+			group = slices.Clone(group)
+			sortPaths(group)
+			group = group[:maxPaths]
+		}
+		turns := estimateTurns(group, ants)
+		if best == nil || turns < bestTurns || (turns == bestTurns && len(group) > len(best)) {
+			best, bestTurns = group, turns
+		}
+	}
+	return best
+}
+
+// fewestMovePaths returns the disjoint paths with the fewest rooms. Sending
+// every ant down one of them makes the fewest moves in total, at the cost of
+// more turns whenever longer paths could have carried some of the ants. The
+// paths are chosen from every path of that length, since the ones a solver
+// picks to save turns may avoid the shortest path altogether.
+func fewestMovePaths(graph *Graph, search string, maxPaths, workers int) ([][]string, error) {
+	moves, ok := distancesFrom(graph, graph.StartRoom)[graph.EndRoom]
+	if !ok {
+		return nil, fmt.Errorf("no valid path found")
+	}
+	candidates := findShortestPaths(graph, graph.StartRoom, search, moves+1, workers)
+	groups := calculateSolutionGroups(candidates, graph.StartRoom, graph.EndRoom)
+	return selectBestGroup(groups, graph.AntCount, maxPaths), nil
+}
+
+// distributeAnts assigns each ant a path, sending it down the path where it
+// would arrive soonest, or the shorter path when two are equally soon, so
+// that short paths fill first. It fails if there are no paths or a path is
+// empty.
+func distributeAnts(paths [][]string, ants int) (map[int][]string, error) {
+	return distributeByLoad(paths, ants, func(path []string) int { return len(path) })
+}
+
+// distributeAntsByCost is distributeAnts for weighted maps, where an ant's
+// time on a path is the path's cost rather than its number of rooms. Each ant
+// goes down the path where its cost plus the ants queued ahead of it is
+// lowest. Because every extra ant on a path costs one more than the last,
+// this greedy choice is the minimum-cost flow of the ants over the paths. On
+// unweighted maps it assigns exactly as distributeAnts does.
+func distributeAntsByCost(graph *Graph, paths [][]string, ants int) (map[int][]string, error) {
+	if len(graph.Weights) == 0 {
+		return distributeAnts(paths, ants)
+	}
+	return distributeByLoad(paths, ants, func(path []string) int {
+		// A path of n rooms costs n-1 when unweighted, and then this
+		// matches distributeAnts.
+		return pathCost(graph, path) + 1
+	})
+}
+
+// distributeByLoad assigns each ant the path with the lowest load, starting
+// from the given base load of each path and adding one for every ant sent
+// down it. Ties go to the path with the lower base load.
+func distributeByLoad(paths [][]string, ants int, base func(path []string) int) (map[int][]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no paths to distribute ants across")
+	}
+	for i, path := range paths {
+		if len(path) == 0 {
+			return nil, fmt.Errorf("path %d is empty", i+1)
+		}
+	}
+
 	assignment := make(map[int][]string)
+	bases := make([]int, len(paths))
 	loads := make([]int, len(paths))
 	for i, path := range paths {
-		loads[i] = len(path)
+		bases[i] = base(path)
+		loads[i] = bases[i]
 	}
 
 	// Distribute ants based on the load.
@@ -242,7 +1279,7 @@ func distributeAnts(paths [][]string, ants int) map[int][]string {
 		minLoad := loads[0]
 		minIndex := 0
 		for i, load := range loads {
-			if load < minLoad {
+			if load < minLoad || (load == minLoad && bases[i] < bases[minIndex]) {
 				minLoad = load
 				minIndex = i
 			}
@@ -251,21 +1288,95 @@ func distributeAnts(paths [][]string, ants int) map[int][]string {
 		loads[minIndex]++
 	}
 
-	// fmt.Println("Assignment:", assignment)
+	return assignment, nil
+}
+
+// parseAssignment builds an assignment from a manual distribution such as
+// "path0=3,path1=2", which puts the first three ants on paths[0] and the next
+// two on paths[1]. The counts must add up to the number of ants.
+func parseAssignment(spec string, paths [][]string, ants int) (map[int][]string, error) {
+	assignment := make(map[int][]string)
+	antID := 1
+	for _, entry := range strings.Split(spec, ",") {
+		name, countStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		indexStr, isPath := strings.CutPrefix(name, "path")
+		if !ok || !isPath {
+			return nil, fmt.Errorf("invalid assignment: %s", entry)
+		}
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 || index >= len(paths) {
+			return nil, fmt.Errorf("invalid assignment path: %s", name)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid assignment count: %s", entry)
+		}
+		for i := 0; i < count; i++ {
+			assignment[antID] = paths[index]
+			antID++
+		}
+	}
+	if antID-1 != ants {
+		return nil, fmt.Errorf("assignment places %d ants but the map has %d", antID-1, ants)
+	}
+	return assignment, nil
+}
+
+// antMove is a single ant stepping through a tunnel into a room.
+type antMove struct {
+	Ant  int
+	From string
+	To   string
+}
+
+// String formats the move as it appears in the output, e.g. "L1-room".
+func (m antMove) String() string {
+	return fmt.Sprintf("L%d-%s", m.Ant, m.To)
+}
+
+// checkSimplePath returns an error if a path visits any room twice.
+func checkSimplePath(path []string) error {
+	seen := make(map[string]bool, len(path))
+	for _, room := range path {
+		if seen[room] {
+			return fmt.Errorf("path visits room %s more than once: %s", room, strings.Join(path, " -> "))
+		}
+		seen[room] = true
+	}
+	return nil
+}
 
-	return assignment
+// simOptions adjusts the rules the ants move by.
+type simOptions struct {
+	// maxMovesPerTurn, when positive, caps how many ants may move in a
+	// single turn, modelling congestion.
+	maxMovesPerTurn int
+	// blocked, when set, is called for each ant held back in a turn because
+	// its next room is full or its tunnel was already used that turn. Of
+	// the ants waiting to leave through the same tunnel, only the first is
+	// reported.
+	blocked func(turn int, move antMove, reason string)
+	// strict, when set, checks each turn against the rules with a
+	// moveValidator before it is emitted, failing on the first violation.
+	strict bool
 }
 
-// getAntMoves prints the movements of ants.
-func getAntMoves(originalAssignment map[int][]string, end string) string {
+// simulateAntMoves steps the ants along their assigned paths, calling emit
+// with the moves made in each turn as soon as the turn is computed. It returns
+// the number of turns, stopping early if emit fails.
+func simulateAntMoves(graph *Graph, originalAssignment map[int][]string, sim simOptions, emit func([]antMove) error) (int, error) {
 	type AntAssignment struct {
 		AntID int
 		Path  []string
 	}
 
-	// Convert the map into a slice.
+	// Convert the map into a slice, making sure no path could trap an ant
+	// in a loop.
 	var assignments []AntAssignment
 	for antID, path := range originalAssignment {
+		if err := checkSimplePath(path); err != nil {
+			return 0, fmt.Errorf("ant %d: %w", antID, err)
+		}
 		assignments = append(assignments, AntAssignment{AntID: antID, Path: path})
 	}
 
@@ -274,107 +1385,685 @@ func getAntMoves(originalAssignment map[int][]string, end string) string {
 		return assignments[i].AntID < assignments[j].AntID
 	})
 
-	antMoves := ""
+	turns := 0
 	antPositions := make(map[int]int)
-	roomFull := make(map[string]bool)
+	occupancy := make(map[string]int)
+	var checker *moveValidator
+	if sim.strict {
+		checker = newMoveValidator(graph)
+	}
+
+	// Ants yet to leave wait in a queue for the first tunnel of their path,
+	// in ant order. Whatever holds back the ant at the head of a queue holds
+	// back every ant behind it, so each turn only the heads are tried and
+	// the waiting ants cost nothing on maps with many ants.
+	var queues [][]AntAssignment
+	queueOf := make(map[[2]string]int)
+	waiting := 0
+	for _, assignment := range assignments {
+		if len(assignment.Path) < 2 {
+			continue
+		}
+		first := [2]string{assignment.Path[0], assignment.Path[1]}
+		i, ok := queueOf[first]
+		if !ok {
+			i = len(queues)
+			queueOf[first] = i
+			queues = append(queues, nil)
+		}
+		queues[i] = append(queues[i], assignment)
+		waiting++
+	}
+	// firstQueue returns the queue whose head comes first among those not
+	// held back, or -1 if there is none.
+	held := make([]bool, len(queues))
+	firstQueue := func() int {
+		first := -1
+		for i, queue := range queues {
+			if len(queue) > 0 && !held[i] && (first < 0 || queue[0].AntID < queues[first][0].AntID) {
+				first = i
+			}
+		}
+		return first
+	}
+
+	// Only the ants on their way are visited each turn, in ant order, so
+	// ants that have finished cost nothing either.
+	var active, next []AntAssignment
 
 	for {
-		var tunnelsUsed = make(map[string]bool)
-		var moveStrings []string
-		finishedAnts := 0
-
-		// Process each ant's movement.
-		for i := range assignments {
-			currentPosition := antPositions[assignments[i].AntID]
-			if currentPosition < len(assignments[i].Path)-1 {
-				nextPosition := currentPosition + 1
-				currentRoom := assignments[i].Path[currentPosition]
-				nextRoom := assignments[i].Path[nextPosition]
-				if !roomFull[nextRoom] && !tunnelsUsed[currentRoom+"->"+nextRoom] {
-					antPositions[assignments[i].AntID] = nextPosition
-					moveStrings = append(moveStrings, fmt.Sprintf("L%d-%s", assignments[i].AntID, nextRoom))
-					if nextRoom != end {
-						roomFull[nextRoom] = true
+		var tunnelsUsed = make(map[[2]string]int)
+		var moves []antMove
+
+		// step moves an ant to the next room of its path if it can,
+		// reporting whether it moved.
+		step := func(assignment AntAssignment) bool {
+			if sim.maxMovesPerTurn > 0 && len(moves) >= sim.maxMovesPerTurn {
+				return false
+			}
+			currentPosition := antPositions[assignment.AntID]
+			nextPosition := currentPosition + 1
+			currentRoom := assignment.Path[currentPosition]
+			nextRoom := assignment.Path[nextPosition]
+			// The start and end rooms hold any number of ants, but a
+			// tunnel carries one ant per turn unless it is wider, so when
+			// the start and end are linked directly the ants still cross
+			// as many at a time as the tunnel carries.
+			roomFree := graph.Unlimited(nextRoom) || occupancy[nextRoom] < graph.Rooms[nextRoom].Capacity
+			tunnel := [2]string{currentRoom, nextRoom}
+			tunnelFree := tunnelsUsed[tunnel] < graph.Width(currentRoom, nextRoom)
+			if sim.blocked != nil && (!roomFree || !tunnelFree) {
+				reason := "room occupied"
+				if roomFree {
+					reason = "tunnel in use"
+				}
+				sim.blocked(turns+1, antMove{Ant: assignment.AntID, From: currentRoom, To: nextRoom}, reason)
+			}
+			if !roomFree || !tunnelFree {
+				return false
+			}
+			antPositions[assignment.AntID] = nextPosition
+			moves = append(moves, antMove{Ant: assignment.AntID, From: currentRoom, To: nextRoom})
+			if !graph.Unlimited(nextRoom) {
+				occupancy[nextRoom]++
+			}
+			if !graph.Unlimited(currentRoom) {
+				occupancy[currentRoom]--
+			}
+			tunnelsUsed[tunnel]++
+			return true
+		}
+		finished := func(assignment AntAssignment) bool {
+			return antPositions[assignment.AntID] == len(assignment.Path)-1
+		}
+
+		// Process the ants on their way and the heads of the queues in ant
+		// order, keeping those that have not finished.
+		clear(held)
+		next = next[:0]
+		queue := firstQueue()
+		for i := 0; i < len(active) || queue >= 0; {
+			if queue >= 0 && (i == len(active) || queues[queue][0].AntID < active[i].AntID) {
+				assignment := queues[queue][0]
+				if step(assignment) {
+					queues[queue] = queues[queue][1:]
+					waiting--
+					if !finished(assignment) {
+						next = append(next, assignment)
 					}
-					roomFull[assignments[i].Path[currentPosition]] = false
-					tunnelsUsed[currentRoom+"->"+nextRoom] = true
-					// fmt.Println("TunnelsUsed:", tunnelsUsed)
+				} else {
+					held[queue] = true
 				}
-			} else {
-				finishedAnts++
+				queue = firstQueue()
+				continue
+			}
+			assignment := active[i]
+			i++
+			if !step(assignment) || !finished(assignment) {
+				next = append(next, assignment)
 			}
 		}
-		fmt.Println()
+		active, next = next, active
 
-		if len(moveStrings) > 0 {
-			antMoves += strings.Join(moveStrings, " ") + "\n"
+		if len(moves) > 0 {
+			turns++
+			if checker != nil {
+				if err := checker.step(moveStrings(moves)); err != nil {
+					return turns, fmt.Errorf("strict check failed: %w", err)
+				}
+			}
+			if err := emit(moves); err != nil {
+				return turns, err
+			}
 		}
 
 		// When all ants have reached the end of their paths, finish.
-		if finishedAnts == len(assignments) {
+		if len(active) == 0 && waiting == 0 {
 			break
 		}
+		// A turn that gets past this check moves at least one ant, so the run
+		// ends within as many turns as there are moves in total.
+		if len(moves) == 0 {
+			return turns, fmt.Errorf("ants are stuck after turn %d", turns)
+		}
+	}
+	return turns, nil
+}
+
+// moveStrings formats a turn of moves as they appear in the output.
+func moveStrings(moves []antMove) []string {
+	turn := make([]string, len(moves))
+	for i, move := range moves {
+		turn[i] = move.String()
 	}
-	return antMoves
+	return turn
+}
+
+// getAntMoves simulates the ants along their assigned paths and returns the
+// moves made in each turn.
+func getAntMoves(graph *Graph, assignment map[int][]string) ([][]string, error) {
+	var antMoves [][]string
+	_, err := simulateAntMoves(graph, assignment, simOptions{}, func(moves []antMove) error {
+		antMoves = append(antMoves, moveStrings(moves))
+		return nil
+	})
+	return antMoves, err
 }
 
-// debugPaths prints all the paths found.
-func debugPaths(paths [][]string) {
-	fmt.Println("All paths found:")
+// writeAntMoves simulates the ants along their assigned paths and writes each
+// turn's moves to out as soon as it is computed, so memory use does not grow
+// with the length of the solution. Each observer is also shown every turn's
+// moves. It returns the number of turns.
+func writeAntMoves(out *moveWriter, graph *Graph, assignment map[int][]string, sim simOptions, observers ...func([]antMove)) (int, error) {
+	return simulateAntMoves(graph, assignment, sim, func(moves []antMove) error {
+		for _, observe := range observers {
+			observe(moves)
+		}
+		return out.writeTurn(moveStrings(moves))
+	})
+}
+
+// debugPaths prints the paths used by the solution.
+func debugPaths(w io.Writer, paths [][]string) {
+	fmt.Fprintln(w, "Paths used:")
 	for i, path := range paths {
-		fmt.Printf("Path %d: %s\n", i+1, strings.Join(path, " -> "))
+		fmt.Fprintf(w, "Path %d: %s\n", i+1, strings.Join(path, " -> "))
+	}
+}
+
+// roomTags returns the tags of each room that has any, or nil if none does.
+func roomTags(graph *Graph) map[string]map[string]string {
+	var tags map[string]map[string]string
+	for name, room := range graph.Rooms {
+		if len(room.Tags) > 0 {
+			if tags == nil {
+				tags = make(map[string]map[string]string)
+			}
+			tags[name] = room.Tags
+		}
+	}
+	return tags
+}
+
+// printTags writes the tags of each tagged room, in room order, e.g.
+// "pool: depth=3, kind=water".
+func printTags(w io.Writer, graph *Graph) {
+	tags := roomTags(graph)
+	if tags == nil {
+		return
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Room tags:")
+	for _, name := range names {
+		pairs := make([]string, 0, len(tags[name]))
+		for key, value := range tags[name] {
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(pairs, ", "))
+	}
+}
+
+// echoMap writes the lines of a map followed by a blank line.
+func echoMap(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writePaths writes each path on a line of its own, e.g. "start a b end",
+// followed by a blank line.
+func writePaths(w io.Writer, paths [][]string) error {
+	for _, path := range paths {
+		if _, err := fmt.Fprintln(w, strings.Join(path, " ")); err != nil {
+			return err
+		}
 	}
+	_, err := fmt.Fprintln(w)
+	return err
 }
 
 // debugAntCount prints the number of ants.
-func debugAntCount(antCount int) {
-	fmt.Printf("Number of ants: %d\n", antCount)
+func debugAntCount(w io.Writer, antCount int) {
+	fmt.Fprintf(w, "Number of ants: %d\n", antCount)
+}
+
+// options holds the settings given on the command line.
+type options struct {
+	command  string // subcommand, or empty to solve
+	filename string
+	second   string // the subcommand's optional second file
+	dot      bool
+	verbose  bool
+	explain  bool
+	algo     string
+	assign   string
+	detour   int
+	parallel int
+	search   string
+	dedupe   bool
+	maxPaths int
+	replay   string
+	color    bool
+	indexed  bool
+	padded   bool
+	ndjson   bool
+	flush    int
+	compress bool
+	multi    bool
+	serve    string
+	minimize string
+	relax    bool
+	output   string
+	binary   string
+	stats    bool
+	count    bool
+	paths    bool
+	noEcho   bool
+	heatmap  bool
+	traceAnt int
+	parse    parseOptions
+	sim      simOptions
+	logger   *slog.Logger
+}
+
+// hiddenFlags names the debugging flags that -h does not list.
+var hiddenFlags = map[string]bool{
+	"assign": true,
+}
+
+// errReported marks the errors of parseArgs that the flag package has
+// already printed.
+var errReported = errors.New("already reported")
+
+// parseArgs parses the command-line arguments, without the program name.
+func parseArgs(args []string) (options, error) {
+	var opts options
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			opts.command, args = args[0], args[1:]
+		}
+	}
+	fs := flag.NewFlagSet("lem-in", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Println("Usage: go run . [flags] <input_file>")
+		fmt.Println("   or: go run . <solve|validate|visualize|render> [flags] <input_file> [file]")
+		fmt.Println("render draws the map as an SVG image in a .svg file, or writes it in Graphviz DOT format to a .dot file or stdout.")
+		// Debugging flags are left out of the help text.
+		visible := flag.NewFlagSet("lem-in", flag.ContinueOnError)
+		visible.SetOutput(os.Stdout)
+		fs.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		visible.PrintDefaults()
+	}
+	fs.BoolVar(&opts.dot, "dot", false, "print the map in Graphviz DOT format instead of solving it")
+	fs.BoolVar(&opts.stats, "stats", false, "print size and connectivity metrics of the map instead of solving it")
+	fs.BoolVar(&opts.explain, "explain", false, "print every candidate path and why it was or wasn't chosen")
+	fs.StringVar(&opts.output, "output", "", "write only the moves and the number of turns to this file")
+	fs.BoolVar(&opts.noEcho, "no-echo", false, "do not print the map before the moves")
+	fs.BoolVar(&opts.paths, "paths", false, "print the chosen paths, one per line, before the moves")
+	fs.BoolVar(&opts.count, "count", false, "print only the number of turns instead of the moves")
+	fs.BoolVar(&opts.heatmap, "heatmap", false, "after the moves, print how many ant-turns were spent in each room")
+	fs.IntVar(&opts.traceAnt, "trace-ant", 0, "after the moves, print the path of this ant and the turn it entered each room (0 for none)")
+	fs.BoolVar(&opts.verbose, "v", false, "print diagnostics about the map and the solution")
+	fs.StringVar(&opts.algo, "algo", "dfs", "solving algorithm: dfs, flow or dinic")
+	fs.StringVar(&opts.minimize, "minimize", "turns", "what to minimize: turns, or moves to send the ants only down the shortest paths")
+	fs.BoolVar(&opts.relax, "relax", false, "experimental: also try paths that share rooms with the chosen ones when that could save turns")
+	fs.IntVar(&opts.maxPaths, "limit-paths", 0, "use at most this many paths (0 for no limit)")
+	fs.IntVar(&opts.parallel, "parallel", 0, "with -algo dfs, search for paths in up to this many goroutines (0 to search sequentially)")
+	fs.StringVar(&opts.search, "paths-algorithm", "dfs", "with -algo dfs, how to enumerate the candidate paths: dfs or bfs")
+	fs.BoolVar(&opts.dedupe, "dedupe-paths", false, "with -algo dfs, drop paths that pass through every room of a cheaper path before grouping")
+	fs.IntVar(&opts.detour, "max-detour", 0, "with -algo dfs, ignore paths more than this many rooms longer than the shortest (0 for no limit)")
+	fs.BoolVar(&opts.padded, "padded", false, "zero-pad the ant numbers to the width of the ant count, e.g. L0001")
+	fs.BoolVar(&opts.indexed, "indexed", false, "prefix each turn of moves with \"Turn N:\"")
+	fs.StringVar(&opts.serve, "serve", "", "instead of reading a file, serve POST /solve requests on this address, e.g. :8080; -algo=dfs is replaced by dinic there")
+	fs.BoolVar(&opts.multi, "multi", false, "solve each of several maps separated by \""+mapSeparator+"\" lines")
+	fs.BoolVar(&opts.compress, "compress", false, "merge consecutive turns whenever the merged turn is still legal")
+	fs.IntVar(&opts.flush, "flush-every", 0, "flush the moves after every this many turns (0 to flush each turn with -ndjson and at the end otherwise)")
+	fs.BoolVar(&opts.ndjson, "ndjson", false, "write each turn as a JSON object on its own line, e.g. {\"turn\":1,\"moves\":[\"L1-a\"]}")
+	fs.StringVar(&opts.binary, "to-binary", "", "convert the map to the binary format, writing it to this file")
+	fs.BoolVar(&opts.color, "color", false, "with -replay or visualize, color the moves when writing to a terminal")
+	fs.StringVar(&opts.replay, "replay", "", "replay the moves saved in this file over the map instead of solving it")
+	fs.StringVar(&opts.parse.start, "start", "", "name of the start room, overriding ##start")
+	fs.StringVar(&opts.parse.end, "end", "", "name of the end room, overriding ##end")
+	fs.IntVar(&opts.parse.maxNameLength, "max-name-length", 0, "reject room names longer than this many characters (0 for no limit)")
+	fs.BoolVar(&opts.parse.lenient, "lenient", false, "collapse duplicate links instead of rejecting the map")
+	fs.BoolVar(&opts.parse.floatCoords, "float-coords", false, "accept fractional room coordinates, used exactly when rendering")
+	fs.BoolVar(&opts.parse.tags, "tags", false, "collect \"# key:value\" comments into the tags of the room that follows and print them")
+	fs.BoolVar(&opts.parse.infer, "infer", false, "when the start or end room is missing, list the rooms that could be it")
+	fs.BoolVar(&opts.sim.strict, "strict-check", false, "check every turn against the movement rules as it is simulated, failing on the first violation")
+	fs.IntVar(&opts.sim.maxMovesPerTurn, "max-moves-per-turn", 0, "let at most this many ants move in one turn (0 for no limit)")
+	fs.StringVar(&opts.assign, "assign", "", "manual ant distribution, e.g. path0=3,path1=2")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("%w: %w", errReported, err)
+	}
+	if opts.serve != "" {
+		// The server reads maps from requests rather than a file.
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return opts, errors.New("-serve takes no input file")
+		}
+		return opts, nil
+	}
+	if commands[opts.command] != "" && fs.NArg() == 2 {
+		opts.filename, opts.second = fs.Arg(0), fs.Arg(1)
+		return opts, nil
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return opts, errors.New("expected exactly one input file")
+	}
+	opts.filename = fs.Arg(0)
+	return opts, nil
 }
 
 // main is the entry point of the program.
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run . <input_file>")
+	opts, err := parseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
+	if err != nil {
+		if !errors.Is(err, errReported) {
+			fmt.Println("ERROR:", err)
+		}
+		os.Exit(1)
+	}
+	if err := run(opts); err != nil {
+		fmt.Println("ERROR:", err)
+		os.Exit(1)
+	}
+}
 
-	graph, start, end, ants := readInput(os.Args[1])
+// run reads the map named in opts and prints what was asked for.
+func run(opts options) error {
+	opts.logger = newLogger(opts.verbose)
+	solver, err := newSolver(opts, opts.logger)
+	if err != nil {
+		return err
+	}
+	if opts.minimize != "turns" && opts.minimize != "moves" {
+		return fmt.Errorf("unknown objective: %s", opts.minimize)
+	}
+	if opts.serve != "" {
+		return serve(opts.serve, solver, opts.parse)
+	}
+	if opts.multi {
+		results, closeResults, err := createResults(opts.output)
+		if err != nil {
+			return err
+		}
+		if err := runMulti(opts, solver, results); err != nil {
+			closeResults()
+			return err
+		}
+		return closeResults()
+	}
 
-	// Debug: Print the number of ants
-	debugAntCount(ants)
+	graph, lines, err := readInput(opts.filename, opts.parse)
+	if err != nil {
+		return err
+	}
+	opts.logger.Debug("map read", "file", opts.filename, "rooms", len(graph.Rooms), "ants", graph.AntCount)
 
-	// Step 2: Find Shortest Paths (BFS)
-	paths := findShortestPaths(graph, start)
-	if len(paths) == 0 {
-		fmt.Println("ERROR: No valid path found")
-		return
+	switch opts.command {
+	case "validate":
+		return validateCommand(graph, opts.second)
+	case "visualize":
+		return visualizeCommand(opts, solver, graph, opts.second)
+	case "render":
+		return renderCommand(graph, opts.second)
 	}
 
-	// Debug: Print all paths found
-	debugPaths(paths)
+	if opts.dot {
+		return graph.ToDOT(os.Stdout)
+	}
+	if opts.stats {
+		printStats(os.Stdout, graph)
+		return nil
+	}
+	if opts.binary != "" {
+		file, err := os.Create(opts.binary)
+		if err != nil {
+			return err
+		}
+		if err := writeBinary(file, graph); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	if opts.replay != "" {
+		return visualizeCommand(opts, solver, graph, opts.replay)
+	}
 
-	solutionGroups := calculateSolutionGroups(paths, start, end)
-	if len(solutionGroups) == 0 {
-		fmt.Println("ERROR: No compatible solution group found")
-		return
+	results, closeResults, err := createResults(opts.output)
+	if err != nil {
+		return err
+	}
+	if err := solveMap(opts, solver, graph, lines, results); err != nil {
+		closeResults()
+		return err
+	}
+	return closeResults()
+}
+
+// newLogger returns the logger for the command line: text records on stderr,
+// including the debug records of the solvers when verbose is set.
+func newLogger(verbose bool) *slog.Logger {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		// Timestamps only clutter the output of a short run.
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+}
+
+// createResults returns where the moves are written: the file named by
+// -output, or stdout when filename is empty. The returned function closes the
+// file.
+func createResults(filename string) (io.Writer, func() error, error) {
+	if filename == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// runMulti solves each map in a file holding several maps separated by
+// mapSeparator lines, separating the results the same way.
+func runMulti(opts options, solver Solver, results io.Writer) error {
+	graphs, lines, err := readMaps(opts.filename, opts.parse)
+	if err != nil {
+		return err
+	}
+	for i, graph := range graphs {
+		if i > 0 {
+			fmt.Fprintln(results, mapSeparator)
+		}
+		if err := solveMap(opts, solver, graph, lines[i], results); err != nil {
+			return fmt.Errorf("map %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// solveMap solves a single map and writes the moves to results, along with
+// any status and debug text the options ask for. As lem-in output does, the
+// moves are preceded by the lines of the map and a blank line, unless they
+// go to an -output file, which holds only the moves and the turn count.
+func solveMap(opts options, solver Solver, graph *Graph, lines []string, results io.Writer) error {
+	if opts.traceAnt < 0 || opts.traceAnt > graph.AntCount {
+		return fmt.Errorf("cannot trace ant %d: the map has %d ants", opts.traceAnt, graph.AntCount)
+	}
+
+	// Status and debug text goes to stderr when stdout carries JSON.
+	// With -count, only the number of turns is printed.
+	var info io.Writer = os.Stdout
+	moves := results
+	if opts.ndjson {
+		info = os.Stderr
+	}
+	if opts.count {
+		info, moves = io.Discard, io.Discard
 	}
 
-	var antMovesPerPath []string
-	for _, solutionGroup := range solutionGroups {
-		// Step 5: Distribute Ants Optimally Across Paths
-		assignment := distributeAnts(solutionGroup, ants)
+	if opts.parse.tags {
+		printTags(info, graph)
+	}
 
-		// Step 6: Print Ant Movements
-		antMovesPerPath = append(antMovesPerPath, getAntMoves(assignment, end))
+	if opts.verbose {
+		debugAntCount(info, graph.AntCount)
+		if rooms := findUnreachableRooms(graph); len(rooms) > 0 {
+			fmt.Fprintln(info, "Unreachable rooms:", strings.Join(rooms, ", "))
+		}
+		if groups := findSymmetricRooms(graph); len(groups) > 0 {
+			names := make([]string, len(groups))
+			for i, group := range groups {
+				names[i] = strings.Join(group, "~")
+			}
+			fmt.Fprintf(info, "Symmetric branches: %s (each multiplies the equivalent paths searched; -max-detour can prune them)\n", strings.Join(names, ", "))
+		}
 	}
 
-	shortestSolution := antMovesPerPath[0]
-	for _, solution := range antMovesPerPath {
-		if strings.Count(solution, "\n") < strings.Count(shortestSolution, "\n") {
-			shortestSolution = solution
+	var paths [][]string
+	var err error
+	if opts.minimize == "moves" {
+		paths, err = fewestMovePaths(graph, opts.search, opts.maxPaths, opts.parallel)
+	} else {
+		paths, err = solver.ChoosePaths(graph)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.relax && opts.minimize != "moves" {
+		// Paths longer than the longest chosen one would only slow the
+		// ants down.
+		candidates := findShortestPaths(graph, graph.StartRoom, opts.search, criticalPath(paths)+1, opts.parallel)
+		if paths, _, err = relaxPaths(graph, paths, candidates, opts.logger); err != nil {
+			return err
 		}
 	}
 
-	fmt.Println(shortestSolution)
-	fmt.Println("Program completed.")
+	if opts.verbose {
+		debugPaths(info, paths)
+	}
+	if opts.explain {
+		explainPaths(info, graph, findShortestPaths(graph, graph.StartRoom, opts.search, 0, opts.parallel), paths)
+	}
+	if opts.verbose {
+		if disjoint := maxDisjointPaths(graph); queueLimited(paths, disjoint, graph.AntCount) {
+			fmt.Fprintf(info, "bottleneck: %d disjoint paths, %d ants, queue-limited\n", disjoint, graph.AntCount)
+		}
+	}
+
+	assignment, err := distributeAntsByCost(graph, paths, graph.AntCount)
+	if err != nil {
+		return err
+	}
+	if opts.assign != "" {
+		assignment, err = parseAssignment(opts.assign, paths, graph.AntCount)
+		if err != nil {
+			return err
+		}
+	}
+
+	var observers []func([]antMove)
+	tracer := newAntTracer(graph.StartRoom)
+	usage := newTunnelUsage()
+	if opts.verbose || opts.traceAnt > 0 {
+		observers = append(observers, tracer.record)
+	}
+	if opts.verbose {
+		observers = append(observers, usage.record)
+		opts.sim.blocked = func(turn int, move antMove, reason string) {
+			opts.logger.Debug("ant blocked", "turn", turn, "ant", move.Ant, "from", move.From, "to", move.To, "reason", reason)
+		}
+	}
+
+	// Stream the moves to stdout as they are computed.
+	out := &moveWriter{w: bufio.NewWriter(moves), indexed: opts.indexed, ndjson: opts.ndjson, flushEvery: opts.flush}
+	if opts.padded {
+		out.padding = len(strconv.Itoa(graph.AntCount))
+	}
+	clean := opts.ndjson || opts.output != ""
+	if !clean && !opts.noEcho && lines != nil {
+		if err := echoMap(out.w, lines); err != nil {
+			return err
+		}
+	}
+	if !clean && opts.paths {
+		if err := writePaths(out.w, paths); err != nil {
+			return err
+		}
+	}
+	write := writeAntMoves
+	if opts.compress {
+		write = writeCompressedMoves
+	}
+	turns, err := write(out, graph, assignment, opts.sim, observers...)
+	if err != nil {
+		return err
+	}
+	if opts.output != "" && !opts.ndjson {
+		// Lines starting with '#' are skipped when the file is replayed.
+		fmt.Fprintf(out.w, "# turns: %d\n", turns)
+	}
+	if err := out.w.Flush(); err != nil {
+		return err
+	}
+
+	if opts.verbose {
+		for ant := 1; ant <= graph.AntCount; ant++ {
+			fmt.Fprintln(info, tracer.trace(ant))
+		}
+		printUsage(info, paths, assignment, usage, turns)
+		fmt.Fprintf(info, "Critical path: %d moves of %d turns\n", criticalPath(paths), turns)
+		if bound := lowerBoundTurns(graph, graph.AntCount); turns == bound {
+			fmt.Fprintf(info, "Lower bound: %d turns, optimal\n", bound)
+		} else {
+			fmt.Fprintf(info, "Lower bound: %d turns\n", bound)
+		}
+	}
+	if opts.traceAnt > 0 {
+		printAntTrace(info, tracer, assignment, opts.traceAnt)
+	}
+	if opts.heatmap {
+		heat, err := roomHeatmap(graph, assignment, simOptions{maxMovesPerTurn: opts.sim.maxMovesPerTurn})
+		if err != nil {
+			return err
+		}
+		printHeatmap(info, heat)
+	}
+	if opts.count {
+		fmt.Fprintln(results, turns)
+	}
+	if opts.verbose {
+		fmt.Fprintln(info, "Program completed.")
+	}
+	return nil
 }
@@ -0,0 +1,1815 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the program itself instead of the tests when runMainEnv is
+// set, so that tests can check how it exits.
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMainEnv makes the test binary run main with its arguments.
+const runMainEnv = "LEMIN_RUN_MAIN"
+
+// exampleMaps are the official example maps kept in testdata.
+var exampleMaps = []string{
+	"example00.txt",
+	"example01.txt",
+	"example02.txt",
+	"example03.txt",
+	"example04.txt",
+	"example05.txt",
+	"example06.txt",
+	"example07.txt",
+}
+
+// mustParse parses a map written out as text, failing the test if it does
+// not parse.
+func mustParse(tb testing.TB, text string, opts parseOptions) *Graph {
+	tb.Helper()
+	graph, _, err := parseMap(strings.NewReader(text), opts)
+	if err != nil {
+		tb.Fatalf("parsing map: %v", err)
+	}
+	return graph
+}
+
+// readExample reads one of the maps in testdata.
+func readExample(tb testing.TB, name string) *Graph {
+	tb.Helper()
+	graph, _, err := readInput(filepath.Join("testdata", name), parseOptions{})
+	if err != nil {
+		tb.Fatalf("reading %s: %v", name, err)
+	}
+	return graph
+}
+
+func BenchmarkExamples(b *testing.B) {
+	for _, name := range exampleMaps {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(strings.TrimSuffix(name, ".txt"), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// Parse the map again each time, so that no iteration
+				// sees state left behind by the last.
+				graph, _, err := ParseMap(bytes.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := (DFSSolver{}).Solve(graph); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRoomCapacity(t *testing.T) {
+	const farm = `2
+##start
+s 0 0
+p 1 0
+q 1 1
+a 2 0 2
+##end
+e 3 0
+s-p
+s-q
+p-a
+q-a
+a-e
+`
+	graph := mustParse(t, farm, parseOptions{})
+	if capacity := graph.Rooms["a"].Capacity; capacity != 2 {
+		t.Fatalf("capacity of a = %d, want 2", capacity)
+	}
+	if capacity := graph.Rooms["p"].Capacity; capacity != 1 {
+		t.Fatalf("capacity of p = %d, want the default of 1", capacity)
+	}
+
+	assignment := map[int][]string{
+		1: {"s", "p", "a", "e"},
+		2: {"s", "q", "a", "e"},
+	}
+	moves, err := getAntMoves(graph, assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"L1-p", "L2-q"}, {"L1-a", "L2-a"}, {"L1-e"}, {"L2-e"}}
+	if !slices.EqualFunc(moves, want, slices.Equal) {
+		t.Errorf("moves = %v, want %v", moves, want)
+	}
+	if err := validateMoves(graph, want); err != nil {
+		t.Errorf("validating two ants in a: %v", err)
+	}
+
+	// With room for one ant, the second waits a turn to enter a.
+	if err := graph.SetCapacity("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMoves(graph, want); err == nil {
+		t.Error("validating two ants in a room that fits one: no error")
+	}
+	moves, err = getAntMoves(graph, assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{{"L1-p", "L2-q"}, {"L1-a"}, {"L1-e", "L2-a"}, {"L2-e"}}
+	if !slices.EqualFunc(moves, want, slices.Equal) {
+		t.Errorf("moves with capacity 1 = %v, want %v", moves, want)
+	}
+}
+
+func TestFindShortestPathWeights(t *testing.T) {
+	const farm = `1
+##start
+s 0 0
+m 1 1
+##end
+e 2 0
+s-e%s
+s-m
+m-e
+`
+	tests := []struct {
+		name   string
+		weight string
+		want   []string
+	}{
+		{"unweighted", "", []string{"s", "e"}},
+		{"heavy direct link", ":10", []string{"s", "m", "e"}},
+		{"cheap direct link", ":2", []string{"s", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := mustParse(t, fmt.Sprintf(farm, tt.weight), parseOptions{})
+			if got := findShortestPath(graph); !slices.Equal(got, tt.want) {
+				t.Errorf("findShortestPath = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// layeredMap returns a map whose rooms form layers of the given width
+// between the start and end rooms, with a one-way tunnel from every room to
+// every room of the next layer, so it has width^layers paths.
+func layeredMap(layers, width, ants int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\n##start\ns 0 0\n##end\ne %d 0\n", ants, layers+1)
+	for layer := 1; layer <= layers; layer++ {
+		for i := 0; i < width; i++ {
+			fmt.Fprintf(&b, "r%d_%d %d %d\n", layer, i, layer, i)
+		}
+	}
+	for i := 0; i < width; i++ {
+		fmt.Fprintf(&b, "s->r1_%d\nr%d_%d->e\n", i, layers, i)
+	}
+	for layer := 1; layer < layers; layer++ {
+		for i := 0; i < width; i++ {
+			for j := 0; j < width; j++ {
+				fmt.Fprintf(&b, "r%d_%d->r%d_%d\n", layer, i, layer+1, j)
+			}
+		}
+	}
+	return b.String()
+}
+
+func BenchmarkCalculateSolutionGroups(b *testing.B) {
+	graph := mustParse(b, layeredMap(3, 7, 10), parseOptions{})
+	paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 0)
+	b.ReportMetric(float64(len(paths)), "paths")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calculateSolutionGroups(paths, graph.StartRoom, graph.EndRoom)
+	}
+}
+
+// blockingMap has a shortest path, s-1-2-e, that blocks two longer paths
+// which don't block each other.
+const blockingMap = `20
+##start
+s 0 0
+1 1 0
+2 2 0
+x 1 1
+y 2 1
+p 1 2
+q 2 2
+##end
+e 3 0
+s-1
+1-2
+2-e
+1-x
+x-y
+y-e
+s-p
+p-q
+q-2
+`
+
+func TestSelectBestGroup(t *testing.T) {
+	graph := mustParse(t, blockingMap, parseOptions{})
+	paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 0)
+	groups := calculateSolutionGroups(paths, graph.StartRoom, graph.EndRoom)
+	if len(groups[0]) != 1 {
+		t.Fatalf("group anchored on the shortest path = %v, want the path alone", groups[0])
+	}
+
+	want := [][]string{{"s", "1", "x", "y", "e"}, {"s", "p", "q", "2", "e"}}
+	best := selectBestGroup(groups, graph.AntCount, 0)
+	sortPaths(best)
+	if !slices.EqualFunc(best, want, slices.Equal) {
+		t.Errorf("best group = %v, want %v", best, want)
+	}
+	if turns := estimateTurns(best, graph.AntCount); turns != 13 {
+		t.Errorf("estimated turns = %d, want 13", turns)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	example := filepath.Join("testdata", "example00.txt")
+	tests := []struct {
+		name string
+		args []string
+		want int
+		// message is a line the output must have; failures other than
+		// those the flag package reports must print it as an ERROR.
+		message string
+	}{
+		{"solved", []string{example}, 0, ""},
+		{"no ants", []string{filepath.Join("testdata", "badexample00.txt")}, 1, "ERROR: invalid number of ants\n"},
+		{"no path", []string{filepath.Join("testdata", "badexample01.txt")}, 1, "ERROR: "},
+		{"self-referencing room", []string{write("self.txt", "1\n##start\ns 0 0\n##end\ne 1 0\ns-e\ne-e\n")}, 1, "ERROR: "},
+		{"missing start", []string{write("start.txt", "1\ns 0 0\n##end\ne 1 0\ns-e\n")}, 1, "ERROR: missing start or end room\n"},
+		{"duplicate room", []string{write("duplicate.txt", "1\n##start\ns 0 0\n##end\ne 1 0\ne 2 0\ns-e\n")}, 1, "ERROR: duplicate room: e\n"},
+		{"missing file", []string{filepath.Join(dir, "missing.txt")}, 1, "ERROR: "},
+		{"no input file", nil, 1, "ERROR: expected exactly one input file\n"},
+		{"two input files", []string{example, example}, 1, "ERROR: expected exactly one input file\n"},
+		{"serve with a file", []string{"-serve", "localhost:0", example}, 1, "ERROR: -serve takes no input file\n"},
+		{"unknown flag", []string{"-no-such-flag", example}, 1, "flag provided but not defined: -no-such-flag\n"},
+		{"bad flag value", []string{"-limit-paths", "many", example}, 1, "invalid value \"many\" for flag -limit-paths"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], tt.args...)
+			cmd.Env = append(os.Environ(), runMainEnv+"=1")
+			output, err := cmd.CombinedOutput()
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d; output:\n%s", code, tt.want, output)
+			}
+			if !bytes.Contains(output, []byte(tt.message)) {
+				t.Errorf("output does not report %q:\n%s", tt.message, output)
+			}
+			// The flag package reports its own errors.
+			if strings.HasPrefix(tt.name, "unknown") || strings.HasPrefix(tt.name, "bad") {
+				if bytes.Contains(output, []byte("ERROR: ")) {
+					t.Errorf("flag error reported twice:\n%s", output)
+				}
+			}
+		})
+	}
+}
+
+func TestReadGzip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "example01.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, wantLines, err := ParseMap(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	// The gzip header is recognized without the extension too.
+	for _, name := range []string{"map.txt.gz", "map.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, compressed.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		graph, lines, err := readInput(path, parseOptions{})
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if diff := want.Diff(graph); diff != nil {
+			t.Errorf("%s differs from the plain map: %v", name, diff)
+		}
+		if !slices.Equal(lines, wantLines) {
+			t.Errorf("%s lines = %q, want %q", name, lines, wantLines)
+		}
+	}
+}
+
+// captureStdout runs fn and returns what it wrote to stdout.
+func captureStdout(tb testing.TB, fn func() error) (string, error) {
+	tb.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	err = fn()
+	w.Close()
+	return <-output, err
+}
+
+// solveText runs solveMap on a map given as text with the given options and
+// returns what it wrote to stdout and to the results.
+func solveText(tb testing.TB, text string, opts options) (stdout, results string) {
+	tb.Helper()
+	graph, lines, err := parseMap(strings.NewReader(text), opts.parse)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if opts.logger == nil {
+		opts.logger = discardLogger
+	}
+	if opts.algo == "" {
+		opts.algo = "dfs"
+	}
+	if opts.search == "" {
+		opts.search = "dfs"
+	}
+	solver, err := newSolver(opts, opts.logger)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var buf bytes.Buffer
+	stdout, err = captureStdout(tb, func() error {
+		return solveMap(opts, solver, graph, lines, &buf)
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return stdout, buf.String()
+}
+
+func BenchmarkSimulateManyAnts(b *testing.B) {
+	graph := mustParse(b, "50000\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n", parseOptions{})
+	assignment, err := distributeAnts([][]string{{"s", "a", "e"}}, graph.AntCount)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		turns, err := simulateAntMoves(graph, assignment, simOptions{}, func([]antMove) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+		if turns != graph.AntCount+1 {
+			b.Fatalf("turns = %d, want %d", turns, graph.AntCount+1)
+		}
+	}
+}
+
+// benchmarkMoves times moving 100000 ants across example01 with emit.
+func benchmarkMoves(b *testing.B, emit func(graph *Graph, assignment map[int][]string) error) {
+	graph := readExample(b, "example01.txt")
+	graph.AntCount = 100000
+	paths, err := DFSSolver{}.ChoosePaths(graph)
+	if err != nil {
+		b.Fatal(err)
+	}
+	assignment, err := distributeAnts(paths, graph.AntCount)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := emit(graph, assignment); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteAntMoves(b *testing.B) {
+	benchmarkMoves(b, func(graph *Graph, assignment map[int][]string) error {
+		out := &moveWriter{w: bufio.NewWriter(io.Discard)}
+		_, err := writeAntMoves(out, graph, assignment, simOptions{})
+		return err
+	})
+}
+
+func BenchmarkGetAntMoves(b *testing.B) {
+	benchmarkMoves(b, func(graph *Graph, assignment map[int][]string) error {
+		_, err := getAntMoves(graph, assignment)
+		return err
+	})
+}
+
+func TestDirectStartEnd(t *testing.T) {
+	graph := mustParse(t, "3\n##start\ns 0 0\n##end\ne 1 0\ns-e\n", parseOptions{})
+	paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 0)
+	if want := [][]string{{"s", "e"}}; !slices.EqualFunc(paths, want, slices.Equal) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	result, err := DFSSolver{}.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tunnel carries one ant per turn.
+	want := [][]string{{"L1-e"}, {"L2-e"}, {"L3-e"}}
+	if !slices.EqualFunc(result.Moves, want, slices.Equal) {
+		t.Errorf("moves = %v, want %v", result.Moves, want)
+	}
+	if err := validateMoves(graph, result.Moves); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "example01.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustParse(t, string(data), parseOptions{})
+	crlf := strings.ReplaceAll(string(data), "\n", "\r\n")
+	// Trailing spaces and tabs are ignored too.
+	crlf = strings.ReplaceAll(crlf, "##end\r\n", "##end \t\r\n")
+	got := mustParse(t, crlf, parseOptions{})
+	if diff := want.Diff(got); diff != nil {
+		t.Errorf("CRLF map differs from the LF map: %v", diff)
+	}
+}
+
+func TestAntCountAfterBlankLinesAndComments(t *testing.T) {
+	const rest = "##start\ns 0 0\n##end\ne 1 0\ns-e\n"
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{"blank lines", "\n\n  \n"},
+		{"comments", "# a map\n#another\n"},
+		{"both", "\n# a map\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := mustParse(t, tt.prefix+"7\n"+rest, parseOptions{})
+			if graph.AntCount != 7 {
+				t.Errorf("ant count = %d, want 7", graph.AntCount)
+			}
+		})
+	}
+
+	// A blank line may also come between the rooms and links.
+	graph := mustParse(t, "2\n\n##start\ns 0 0\n\n##end\ne 1 0\n\ns-e\n", parseOptions{})
+	if !graph.Connected("s", "e") {
+		t.Error("link after a blank line was not added")
+	}
+}
+
+func TestClone(t *testing.T) {
+	original := mustParse(t, `2
+##start
+s 0 0
+# kind:nest
+a 1 0 2
+##end
+e 2 0
+s-a:3
+a-e*2
+`, parseOptions{tags: true})
+	want := mustParse(t, "2\n##start\ns 0 0\n# kind:nest\na 1 0 2\n##end\ne 2 0\ns-a:3\na-e*2\n", parseOptions{tags: true})
+	clone := original.Clone()
+	if diff := original.Diff(clone); diff != nil {
+		t.Fatalf("clone differs from the original: %v", diff)
+	}
+
+	clone.Connections["s"][0] = "e"
+	if err := clone.AddRoom("b", 3, 0, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.AddConnection("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	clone.Weights["s"]["a"] = 5
+	clone.Widths["a"]["e"] = 1
+	clone.Rooms["a"].Tags["kind"] = "water"
+	clone.AntCount = 10
+
+	if diff := want.Diff(original); diff != nil {
+		t.Errorf("changing the clone changed the original: %v", diff)
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	graph := mustParse(t, `5
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+##end
+e 3 0
+s-a
+a-e
+s-b
+b-c
+c-e
+`, parseOptions{})
+	paths := [][]string{{"s", "a", "e"}, {"s", "b", "c", "e"}}
+
+	tests := []struct {
+		spec  string
+		turns int
+	}{
+		// Every ant on the short path: one more turn per ant after the first.
+		{"path0=5", 6},
+		{"path0=5,path1=0", 6},
+		// Every ant on the long path.
+		{"path1=5", 7},
+		// The split distributeAnts would choose.
+		{"path0=3,path1=2", 4},
+	}
+	for _, tt := range tests {
+		assignment, err := parseAssignment(tt.spec, paths, graph.AntCount)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		moves, err := getAntMoves(graph, assignment)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if len(moves) != tt.turns {
+			t.Errorf("%s: %d turns, want %d", tt.spec, len(moves), tt.turns)
+		}
+		if err := validateMoves(graph, moves); err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+		}
+	}
+
+	for _, spec := range []string{"path0=4", "path0=3,path1=3", "path2=5", "path0=-1,path1=6", "p0=5", "path0"} {
+		if _, err := parseAssignment(spec, paths, graph.AntCount); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+	}
+}
+
+func TestDuplicateLinks(t *testing.T) {
+	const farm = `3
+##start
+s 0 0
+a 1 0
+##end
+e 2 0
+s-a
+a-e
+a-s
+s-a
+`
+	_, _, err := parseMap(strings.NewReader(farm), parseOptions{})
+	if err == nil || !strings.Contains(err.Error(), `identical connection already exists: "a-s"`) {
+		t.Errorf("strict parse: err = %v, want the duplicate a-s reported", err)
+	}
+
+	graph := mustParse(t, farm, parseOptions{lenient: true})
+	if got := graph.Connections["s"]; !slices.Equal(got, []string{"a"}) {
+		t.Errorf("s links to %v, want [a]", got)
+	}
+	if got := graph.Degree("a"); got != 2 {
+		t.Errorf("a has degree %d, want 2", got)
+	}
+	result, err := (DFSSolver{}).Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Turns != 4 {
+		t.Errorf("lenient map solved in %d turns, want 4", result.Turns)
+	}
+}
+
+func TestFindShortestPathsOrder(t *testing.T) {
+	const rooms = "3\n##start\ns 0 0\na 1 0\nb 1 1\nc 1 2\n##end\ne 2 0\n"
+	links := []string{"s-a", "s-b", "s-c", "a-e", "b-e", "c-e", "a-b"}
+	want := [][]string{
+		{"s", "a", "e"},
+		{"s", "b", "e"},
+		{"s", "c", "e"},
+		{"s", "a", "b", "e"},
+		{"s", "b", "a", "e"},
+	}
+
+	// The same map with its links listed forwards and backwards must give
+	// the same paths in the same order, run after run, with either search.
+	reversed := slices.Clone(links)
+	slices.Reverse(reversed)
+	for _, order := range [][]string{links, reversed} {
+		graph := mustParse(t, rooms+strings.Join(order, "\n")+"\n", parseOptions{})
+		for _, search := range pathSearches {
+			for run := 0; run < 5; run++ {
+				got := findShortestPaths(graph, graph.StartRoom, search, 0, 1)
+				if !slices.EqualFunc(got, want, slices.Equal) {
+					t.Fatalf("%s search with links %v: paths = %v, want %v", search, order, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestStartEndFlags(t *testing.T) {
+	const farm = "2\nhome 0 0\nmid 1 0\nexit 2 0\nhome-mid\nmid-exit\n"
+	opts, err := parseArgs([]string{"-start=home", "-end=exit", "map.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := mustParse(t, farm, opts.parse)
+	if graph.StartRoom != "home" || graph.EndRoom != "exit" {
+		t.Errorf("start, end = %s, %s; want home, exit", graph.StartRoom, graph.EndRoom)
+	}
+	if !graph.Rooms["home"].IsStart || !graph.Rooms["exit"].IsEnd {
+		t.Error("named rooms are not marked as the start and end")
+	}
+	if result, err := (DFSSolver{}).Solve(graph); err != nil || result.Turns != 3 {
+		t.Errorf("Solve = %d turns, %v; want 3 turns", result.Turns, err)
+	}
+
+	// The flags override the commands in the map.
+	graph = mustParse(t, "2\n##start\nhome 0 0\nmid 1 0\n##end\nexit 2 0\nhome-mid\nmid-exit\n", parseOptions{start: "mid"})
+	if graph.StartRoom != "mid" || graph.Rooms["home"].IsStart {
+		t.Errorf("start = %s, want mid alone", graph.StartRoom)
+	}
+
+	for _, opts := range []parseOptions{{start: "nowhere", end: "exit"}, {start: "home", end: "nowhere"}, {start: "home"}} {
+		if _, _, err := parseMap(strings.NewReader(farm), opts); err == nil {
+			t.Errorf("start %q, end %q: no error", opts.start, opts.end)
+		}
+	}
+}
+
+func TestRoomNames(t *testing.T) {
+	// Λ is two bytes in UTF-8; only its rune is compared with 'L'.
+	graph := mustParse(t, "1\n##start\nΛ1 0 0\n##end\nrüm 1 0\nΛ1-rüm\n", parseOptions{maxNameLength: 3})
+	if graph.StartRoom != "Λ1" || !graph.Connected("Λ1", "rüm") {
+		t.Errorf("start = %q, want Λ1 linked to rüm", graph.StartRoom)
+	}
+
+	long := strings.Repeat("ü", 100000)
+	if err := validateRoomName(long, 0); err != nil {
+		t.Errorf("long name without a limit: %v", err)
+	}
+	err := validateRoomName(long, 64)
+	if err == nil || !strings.Contains(err.Error(), "longer than 64 characters") {
+		t.Errorf("long name: err = %v, want it longer than 64 characters", err)
+	} else if len(err.Error()) > 100 {
+		t.Errorf("error quotes the whole name: %d bytes", len(err.Error()))
+	}
+	if err := validateRoomName(strings.Repeat("ü", 64), 64); err != nil {
+		t.Errorf("name of exactly 64 runes: %v", err)
+	}
+
+	for _, name := range []string{"L1", "Lünen", "#a", "\xffa"} {
+		if err := validateRoomName(name, 0); err == nil {
+			t.Errorf("%q: no error", name)
+		}
+	}
+	if _, _, err := parseMap(strings.NewReader("1\n##start\nL1 0 0\n##end\ne 1 0\nL1-e\n"), parseOptions{}); err == nil {
+		t.Error("map with a room named L1: no error")
+	}
+}
+
+func TestAddConnection(t *testing.T) {
+	graph := NewGraph()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := graph.AddRoom(name, 0, 0, false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := graph.AddConnection("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range [][2]string{{"a", "b"}, {"b", "a"}} {
+		err := graph.AddConnection(link[0], link[1])
+		if !errors.Is(err, errDuplicateConnection) {
+			t.Errorf("adding %s-%s again: err = %v, want errDuplicateConnection", link[0], link[1], err)
+		}
+	}
+	if !slices.Equal(graph.Connections["a"], []string{"b"}) || !slices.Equal(graph.Connections["b"], []string{"a"}) {
+		t.Errorf("connections = %v, want a single a-b tunnel", graph.Connections)
+	}
+
+	// A one-way tunnel blocks a two-way tunnel over it, but not the way back.
+	if err := graph.AddOneWayConnection("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.AddConnection("c", "b"); !errors.Is(err, errDuplicateConnection) {
+		t.Errorf("c-b over b->c: err = %v, want errDuplicateConnection", err)
+	}
+	if err := graph.AddOneWayConnection("c", "b"); err != nil {
+		t.Errorf("c->b beside b->c: %v", err)
+	}
+
+	if err := graph.AddConnection("a", "nowhere"); err == nil || errors.Is(err, errDuplicateConnection) {
+		t.Errorf("link to a missing room: err = %v", err)
+	}
+}
+
+func TestNonSimplePath(t *testing.T) {
+	graph := mustParse(t, "2\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\na-b\na-e\n", parseOptions{})
+	loop := []string{"s", "a", "b", "a", "e"}
+	assignment := map[int][]string{1: {"s", "a", "e"}, 2: loop}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := getAntMoves(graph, assignment)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "path visits room a more than once: s -> a -> b -> a -> e") {
+			t.Errorf("err = %v, want the repeated room a reported", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("simulating a path with a loop did not finish")
+	}
+}
+
+func TestLinksBeforeRooms(t *testing.T) {
+	const links = "s-a\na-e\n"
+	const rooms = "##start\ns 0 0\na 1 0\n##end\ne 2 0\n"
+	graph := mustParse(t, "2\n"+links+rooms, parseOptions{})
+	if diff := mustParse(t, "2\n"+rooms+links, parseOptions{}).Diff(graph); diff != nil {
+		t.Errorf("links first gives a different map: %v", diff)
+	}
+
+	// The checks on links still apply once the rooms are known.
+	for _, tt := range []struct{ text, err string }{
+		{"2\ns-a\na-s\n" + rooms + "a-e\n", `identical connection already exists: "a-s"`},
+		{"2\na-a\n" + links + rooms, `self referencing room: "a-a"`},
+		{"2\ns-x\n" + links + rooms, `invalid connection: "s-x"`},
+	} {
+		_, _, err := parseMap(strings.NewReader(tt.text), parseOptions{})
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("err = %v, want %s", err, tt.err)
+		}
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{"empty.txt": "", "blank.txt": "  \n\t\n\r\n\n"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readInput(path, parseOptions{}); err == nil || err.Error() != "empty input" {
+			t.Errorf("%s: err = %v, want empty input", name, err)
+		}
+
+		cmd := exec.Command(os.Args[0], path)
+		cmd.Env = append(os.Environ(), runMainEnv+"=1")
+		output, err := cmd.CombinedOutput()
+		if err == nil || string(output) != "ERROR: empty input\n" {
+			t.Errorf("%s: printed %q (%v), want ERROR: empty input", name, output, err)
+		}
+	}
+}
+
+func TestMaxMovesPerTurn(t *testing.T) {
+	// Two disjoint paths of two tunnels each carry three ants in three
+	// turns, moving up to two ants at once.
+	const farm = "3\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\na-e\ns-b\nb-e\n"
+	graph := mustParse(t, farm, parseOptions{})
+	assignment, err := distributeAnts([][]string{{"s", "a", "e"}, {"s", "b", "e"}}, graph.AntCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ limit, turns int }{{0, 3}, {2, 4}, {1, 6}} {
+		var turns [][]string
+		n, err := simulateAntMoves(graph, assignment, simOptions{maxMovesPerTurn: tt.limit}, func(moves []antMove) error {
+			turns = append(turns, moveStrings(moves))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.turns || len(turns) != tt.turns {
+			t.Errorf("limit %d: %d turns, want %d", tt.limit, n, tt.turns)
+		}
+		for i, moves := range turns {
+			if tt.limit > 0 && len(moves) > tt.limit {
+				t.Errorf("limit %d: turn %d has %d moves: %v", tt.limit, i+1, len(moves), moves)
+			}
+		}
+		if err := validateMoves(graph, turns); err != nil {
+			t.Errorf("limit %d: %v", tt.limit, err)
+		}
+	}
+
+	// The flag reaches the simulation through solveMap.
+	_, results := solveText(t, farm, options{noEcho: true, sim: simOptions{maxMovesPerTurn: 1}})
+	want := "L1-a\nL1-e\nL2-b\nL2-e\nL3-a\nL3-e\n"
+	if results != want {
+		t.Errorf("moves with -max-moves-per-turn=1:\n%s\nwant:\n%s", results, want)
+	}
+}
+
+func TestEmptyGroups(t *testing.T) {
+	for _, tt := range []struct {
+		paths [][]string
+		err   string
+	}{
+		{nil, "no paths to distribute ants across"},
+		{[][]string{}, "no paths to distribute ants across"},
+		{[][]string{{"s", "e"}, {}}, "path 2 is empty"},
+	} {
+		if _, err := distributeAnts(tt.paths, 3); err == nil || err.Error() != tt.err {
+			t.Errorf("distributeAnts(%v): err = %v, want %s", tt.paths, err, tt.err)
+		}
+	}
+
+	if groups := calculateSolutionGroups(nil, "s", "e"); len(groups) != 0 {
+		t.Errorf("groups of no paths = %v, want none", groups)
+	}
+	if groups := calculateSolutionGroups([][]string{{}}, "s", "e"); len(groups) != 0 {
+		t.Errorf("groups of an empty path = %v, want none", groups)
+	}
+	groups := calculateSolutionGroups([][]string{{}, {"s", "a", "e"}, {}, {"s", "b", "e"}}, "s", "e")
+	if len(groups) != 2 {
+		t.Errorf("got %d groups, want 2: %v", len(groups), groups)
+	}
+	for _, group := range groups {
+		for _, path := range group {
+			if len(path) == 0 {
+				t.Errorf("group %v holds an empty path", group)
+			}
+		}
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	const farm = "1\n##start\ns 0 0\n##end\ne 1 0\ns-e\n"
+	graph := mustParse(t, "#format 1\n"+farm, parseOptions{})
+	if diff := mustParse(t, farm, parseOptions{}).Diff(graph); diff != nil {
+		t.Errorf("#format 1 changes the map: %v", diff)
+	}
+	// The declaration may follow blank lines but is only read first.
+	mustParse(t, "\n#format 1\n"+farm, parseOptions{})
+	mustParse(t, "1\n#format 2\n##start\ns 0 0\n##end\ne 1 0\ns-e\n", parseOptions{})
+
+	for text, want := range map[string]string{
+		"#format 2\n":   "unsupported format version: 2",
+		"#format 0\n":   "unsupported format version: 0",
+		"#format two\n": "invalid format version: two",
+	} {
+		_, _, err := parseMap(strings.NewReader(text+farm), parseOptions{})
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: err = %v, want %s", text, err, want)
+		}
+	}
+}
+
+func TestRoomsNamedStartAndEnd(t *testing.T) {
+	// The rooms called start and end are ordinary rooms on the way from
+	// home to exit.
+	const farm = `2
+##start
+home 0 0
+start 1 0
+end 2 0
+##end
+exit 3 0
+home-start
+start-end
+end-exit
+`
+	graph := mustParse(t, farm, parseOptions{})
+	if graph.StartRoom != "home" || graph.EndRoom != "exit" {
+		t.Fatalf("start, end = %s, %s; want home, exit", graph.StartRoom, graph.EndRoom)
+	}
+	if graph.Rooms["start"].IsStart || graph.Rooms["end"].IsEnd {
+		t.Error("rooms named start and end are marked by their names")
+	}
+	if graph.Unlimited("start") || graph.Unlimited("end") {
+		t.Error("rooms named start and end hold any number of ants")
+	}
+
+	result, err := DFSSolver{}.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"L1-start"}, {"L1-end", "L2-start"}, {"L1-exit", "L2-end"}, {"L2-exit"}}
+	if !slices.EqualFunc(result.Moves, want, slices.Equal) {
+		t.Errorf("moves = %v, want %v", result.Moves, want)
+	}
+
+	var buf bytes.Buffer
+	if err := visualizeAntMovements(&buf, graph, result.Moves, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Turn 1: L1-start\n  home: 1 waiting\n  start: L1\n") {
+		t.Errorf("visualizer does not show L1 in the room named start:\n%s", buf.String())
+	}
+}
+
+func TestBlockedAnts(t *testing.T) {
+	type block struct {
+		turn   int
+		move   antMove
+		reason string
+	}
+	blocks := func(farm string) []block {
+		t.Helper()
+		graph := mustParse(t, farm, parseOptions{})
+		paths, err := DFSSolver{}.ChoosePaths(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assignment, err := distributeAnts(paths, graph.AntCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []block
+		sim := simOptions{blocked: func(turn int, move antMove, reason string) {
+			got = append(got, block{turn, move, reason})
+		}}
+		if _, err := simulateAntMoves(graph, assignment, sim, func([]antMove) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// Each ant waits for the one before it to leave a.
+	got := blocks("3\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n")
+	want := []block{
+		{1, antMove{Ant: 2, From: "s", To: "a"}, "room occupied"},
+		{2, antMove{Ant: 3, From: "s", To: "a"}, "room occupied"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("blocked through a = %v, want %v", got, want)
+	}
+
+	// The end room holds any number of ants, but its one tunnel carries one
+	// ant per turn.
+	got = blocks("2\n##start\ns 0 0\n##end\ne 1 0\ns-e\n")
+	want = []block{{1, antMove{Ant: 2, From: "s", To: "e"}, "tunnel in use"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("blocked through s-e = %v, want %v", got, want)
+	}
+
+	var log bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	solveText(t, "2\n##start\ns 0 0\n##end\ne 1 0\ns-e\n", options{verbose: true, logger: logger})
+	if !strings.Contains(log.String(), `msg="ant blocked" turn=1 ant=2 from=s to=e reason="tunnel in use"`) {
+		t.Errorf("-v log does not report the blocked ant:\n%s", log.String())
+	}
+}
+
+func TestMultipleMaps(t *testing.T) {
+	var text strings.Builder
+	var want strings.Builder
+	names := []string{"example00.txt", "example03.txt"}
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 {
+			text.WriteString(mapSeparator + "\n")
+			want.WriteString(mapSeparator + "\n")
+		}
+		text.Write(data)
+		text.WriteString("\n")
+		_, results := solveText(t, string(data), options{noEcho: true})
+		want.WriteString(results)
+	}
+	file := filepath.Join(t.TempDir(), "maps.txt")
+	if err := os.WriteFile(file, []byte(text.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	graphs, lines, err := readMaps(file, parseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graphs) != len(names) || len(lines) != len(names) {
+		t.Fatalf("read %d maps, want %d", len(graphs), len(names))
+	}
+	for i, name := range names {
+		if diff := readExample(t, name).Diff(graphs[i]); diff != nil {
+			t.Errorf("map %d differs from %s: %v", i+1, name, diff)
+		}
+	}
+
+	opts := options{filename: file, multi: true, noEcho: true, algo: "dfs", search: "dfs", logger: discardLogger}
+	solver, err := newSolver(opts, opts.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results bytes.Buffer
+	if err := runMulti(opts, solver, &results); err != nil {
+		t.Fatal(err)
+	}
+	if results.String() != want.String() {
+		t.Errorf("runMulti wrote:\n%s\nwant:\n%s", results.String(), want.String())
+	}
+
+	// Errors name the map they are in.
+	if err := os.WriteFile(file, []byte(text.String()+mapSeparator+"\n0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readMaps(file, parseOptions{}); err == nil || err.Error() != "map 3: invalid number of ants" {
+		t.Errorf("bad third map: err = %v", err)
+	}
+}
+
+func TestDefaultOutput(t *testing.T) {
+	runMain := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Env = append(os.Environ(), runMainEnv+"=1")
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(output)
+	}
+	example := filepath.Join("testdata", "example00.txt")
+
+	// The map as given, a blank line, then one turn of moves per line.
+	want := `4
+##start
+0 0 3
+2 2 5
+3 4 0
+##end
+1 8 3
+0-2
+2-3
+3-1
+
+L1-2
+L1-3 L2-2
+L1-1 L2-3 L3-2
+L2-1 L3-3 L4-2
+L3-1 L4-3
+L4-1
+`
+	if got := runMain(example); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	verbose := runMain("-v", example)
+	for _, line := range []string{"Number of ants: 4\n", "Paths used:\n", "Program completed.\n"} {
+		if !strings.Contains(verbose, line) {
+			t.Errorf("-v output lacks %q:\n%s", line, verbose)
+		}
+	}
+	if !strings.Contains(verbose, "\n\n"+want[strings.Index(want, "L1-2"):]) {
+		t.Errorf("-v output does not hold the moves after a blank line:\n%s", verbose)
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	const text = "3\n#comment\n##start\ns 0 0\n\n a 1 0 \n##end\ne 2 0\ns-a\na-e"
+	graph, lines, err := ParseMap(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lines come back as written, comments, blank lines and spaces included.
+	if want := strings.Split(text, "\n"); !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	want := NewGraph()
+	want.AntCount = 3
+	for _, room := range []struct {
+		name       string
+		x          int
+		start, end bool
+	}{{"s", 0, true, false}, {"a", 1, false, false}, {"e", 2, false, true}} {
+		if err := want.AddRoom(room.name, room.x, 0, room.start, room.end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, link := range [][2]string{{"s", "a"}, {"a", "e"}} {
+		if err := want.AddConnection(link[0], link[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := want.Diff(graph); diff != nil {
+		t.Errorf("graph differs: %v", diff)
+	}
+
+	if _, lines, err := ParseMap(strings.NewReader("0\n")); err == nil || lines != nil {
+		t.Errorf("bad map: lines = %q, err = %v; want an error alone", lines, err)
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	for _, name := range exampleMaps {
+		cmd := exec.Command(os.Args[0], "-count", filepath.Join("testdata", name))
+		cmd.Env = append(os.Environ(), runMainEnv+"=1")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, output)
+		}
+		if want := fmt.Sprintf("%d\n", optimalTurns[name]); string(output) != want {
+			t.Errorf("%s: printed %q, want %q", name, output, want)
+		}
+	}
+
+	// Nothing but the number, even with the options that add to the output.
+	data, err := os.ReadFile(filepath.Join("testdata", "example00.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, results := solveText(t, string(data), options{count: true, verbose: true, paths: true, heatmap: true, traceAnt: 1})
+	if stdout != "" || results != "6\n" {
+		t.Errorf("printed %q and %q, want only 6", stdout, results)
+	}
+}
+
+func TestDistributeAnts(t *testing.T) {
+	graph := mustParse(t, `4
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+d 3 1
+##end
+e 4 0
+s-a
+a-e
+s-b
+b-c
+c-d
+d-e
+`, parseOptions{})
+	short := []string{"s", "a", "e"}
+	long := []string{"s", "b", "c", "d", "e"}
+
+	// Ant 3 would arrive in turn 4 either way, so it takes the short path:
+	// three ants there finish in turn 4, as does the one on the long path.
+	// Two and two would take 5 turns.
+	for _, paths := range [][][]string{{short, long}, {long, short}} {
+		assignment, err := distributeAnts(paths, graph.AntCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[int][]string{1: short, 2: short, 3: short, 4: long}
+		if !maps.EqualFunc(assignment, want, slices.Equal) {
+			t.Errorf("paths %v: assignment = %v, want %v", paths, assignment, want)
+		}
+		moves, err := getAntMoves(graph, assignment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(moves) != 4 || estimateTurns(paths, graph.AntCount) != 4 {
+			t.Errorf("paths %v: %d turns, estimated %d, want 4", paths, len(moves), estimateTurns(paths, graph.AntCount))
+		}
+	}
+}
+
+func TestStuckAnts(t *testing.T) {
+	// The two ants cross a and b in opposite directions, so after the first
+	// turn each waits for the room the other is in.
+	graph := mustParse(t, "2\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\ns-b\na-b\na-e\nb-e\n", parseOptions{})
+	assignment := map[int][]string{1: {"s", "a", "b", "e"}, 2: {"s", "b", "a", "e"}}
+
+	done := make(chan error, 1)
+	var turns [][]string
+	go func() {
+		_, err := simulateAntMoves(graph, assignment, simOptions{}, func(moves []antMove) error {
+			turns = append(turns, moveStrings(moves))
+			return nil
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "ants are stuck after turn 1" {
+			t.Errorf("err = %v, want ants are stuck after turn 1", err)
+		}
+		if want := [][]string{{"L1-a", "L2-b"}}; !slices.EqualFunc(turns, want, slices.Equal) {
+			t.Errorf("turns = %v, want %v", turns, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("simulating deadlocked ants did not finish")
+	}
+}
+
+func TestMissingAntCount(t *testing.T) {
+	for text, want := range map[string]string{
+		"room1 1 2\n##start\ns 0 0\n##end\ne 1 0\ns-e\n": `missing number of ants: map starts with "room1 1 2"`,
+		"##start\ns 0 0\n##end\ne 1 0\ns-e\n":            `missing number of ants: map starts with "s 0 0"`,
+		"s-e\n":                                          `missing number of ants: map starts with "s-e"`,
+		"ants\n##start\ns 0 0\n##end\ne 1 0\ns-e\n":      "invalid number of ants",
+		"-3\n##start\ns 0 0\n##end\ne 1 0\ns-e\n":        "invalid number of ants",
+	} {
+		_, _, err := parseMap(strings.NewReader(text), parseOptions{})
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: err = %v, want %s", text, err, want)
+		}
+	}
+}
+
+func TestRemoveRoom(t *testing.T) {
+	graph := mustParse(t, `1
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+##end
+e 2 0
+s-a:3
+a-e*2
+s-b
+b-a
+c->a
+c-e
+`, parseOptions{})
+	if err := graph.RemoveRoom("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := graph.Rooms["a"]; ok {
+		t.Error("room a is still in the graph")
+	}
+	for room, neighbors := range graph.Connections {
+		if room == "a" || slices.Contains(neighbors, "a") {
+			t.Errorf("%s still has a tunnel to a: %v", room, neighbors)
+		}
+		if _, ok := graph.Weights[room]["a"]; ok {
+			t.Errorf("weight of %s-a is left behind", room)
+		}
+		if _, ok := graph.Widths[room]["a"]; ok {
+			t.Errorf("width of %s-a is left behind", room)
+		}
+	}
+	want := mustParse(t, "1\n##start\ns 0 0\nb 1 1\nc 2 1\n##end\ne 2 0\ns-b\nc-e\n", parseOptions{})
+	if diff := want.Diff(graph); diff != nil {
+		t.Errorf("graph after removing a differs: %v", diff)
+	}
+
+	if err := graph.RemoveRoom("a"); err == nil {
+		t.Error("removing a missing room: no error")
+	}
+	if err := graph.RemoveRoom("e"); err != nil {
+		t.Fatal(err)
+	}
+	if graph.EndRoom != "" || graph.Validate() == nil {
+		t.Errorf("end room = %q after removing it, want none", graph.EndRoom)
+	}
+}
+
+func TestRemoveLink(t *testing.T) {
+	graph := mustParse(t, "1\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a:2\na-e*3\ns-e\n", parseOptions{})
+	if err := graph.RemoveLink("e", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Connected("a", "e") || graph.Connected("e", "a") || graph.Width("a", "e") != 1 {
+		t.Error("tunnel a-e is still there")
+	}
+	if !graph.Connected("a", "s") || graph.Weight("s", "a") != 2 || !graph.Connected("s", "e") {
+		t.Error("removing a-e changed other tunnels")
+	}
+	if err := graph.RemoveLink("a", "e"); err == nil {
+		t.Error("removing a missing tunnel: no error")
+	}
+
+	// A one-way tunnel is removed from the side that lists it.
+	if err := graph.AddOneWayConnection("e", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.RemoveLink("a", "e"); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Connected("e", "a") {
+		t.Error("one-way tunnel e->a is still there")
+	}
+}
+
+func TestTabSeparatedRooms(t *testing.T) {
+	text := "2\n##start\ns\t0\t0\na  1 \t 0\t2\n##end\ne\t2   0\ns-a\na-e\n"
+	graph, lines, err := ParseMap(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := mustParse(t, "2\n##start\ns 0 0\na 1 0 2\n##end\ne 2 0\ns-a\na-e\n", parseOptions{}).Diff(graph); diff != nil {
+		t.Errorf("tab-separated rooms parse differently: %v", diff)
+	}
+
+	// The map is echoed exactly as written, tabs and all.
+	_, results := solveText(t, text, options{})
+	if want := text + "\n"; !strings.HasPrefix(results, want) {
+		t.Errorf("echo:\n%q\nwant it to start with:\n%q", results, want)
+	}
+	if want := strings.Split(strings.TrimSuffix(text, "\n"), "\n"); !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	// Errors quote the line as written.
+	_, _, err = parseMap(strings.NewReader("1\nb\t1\tx\n"), parseOptions{})
+	if err == nil || err.Error() != `invalid y coordinate: "x"` {
+		t.Errorf("err = %v", err)
+	}
+	_, _, err = parseMap(strings.NewReader("1\nb\t1\t2\t3\t4\n"), parseOptions{})
+	if err == nil || err.Error() != `invalid room format: "b\t1\t2\t3\t4"` {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestStartIsEnd(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		opts parseOptions
+	}{
+		{"1\n##start\n##end\nx 0 0\ny 1 0\nx-y\n", parseOptions{}},
+		{"1\n##start\nx 0 0\n##end\ny 1 0\nx-y\n", parseOptions{end: "x"}},
+		{"1\n##start\nx 0 0\n##end\ny 1 0\nx-y\n", parseOptions{start: "y", end: "y"}},
+	} {
+		_, _, err := parseMap(strings.NewReader(tt.text), tt.opts)
+		if err == nil || !strings.HasPrefix(err.Error(), "start and end are the same room: ") {
+			t.Errorf("%q with %+v: err = %v, want start and end to be the same room", tt.text, tt.opts, err)
+		}
+	}
+
+	graph := NewGraph()
+	graph.AntCount = 1
+	if err := graph.AddRoom("x", 0, 0, true, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Validate(); err == nil || err.Error() != "start and end are the same room: x" {
+		t.Errorf("Validate: err = %v", err)
+	}
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	// A cheap path of two tunnels and an expensive one of three.
+	const farm = `%d
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+##end
+e 3 0
+s-a%s
+a-e%s
+s-b%s
+b-c%s
+c-e%s
+`
+	paths := [][]string{{"s", "a", "e"}, {"s", "b", "c", "e"}}
+	tests := []struct {
+		name    string
+		weights [5]string
+		ants    int
+		want    []int // ants on the cheap and the expensive path
+	}{
+		// Unweighted, the ants are spread by room count as before.
+		{"unweighted", [5]string{}, 9, []int{5, 4}},
+		{"cheap short path", [5]string{":1", ":1", ":5", ":5", ":5"}, 9, []int{9, 0}},
+		{"close costs", [5]string{":2", ":2", ":2", ":2", ":1"}, 9, []int{5, 4}},
+		{"many ants", [5]string{":1", ":1", ":5", ":5", ":5"}, 20, []int{17, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := fmt.Sprintf(farm, tt.ants, tt.weights[0], tt.weights[1], tt.weights[2], tt.weights[3], tt.weights[4])
+			graph := mustParse(t, text, parseOptions{})
+			assignment, err := distributeAntsByCost(graph, paths, graph.AntCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := antsPerPath(paths, assignment); !slices.Equal(got, tt.want) {
+				t.Errorf("ants per path = %v, want %v", got, tt.want)
+			}
+			if tt.name == "unweighted" {
+				want, err := distributeAnts(paths, graph.AntCount)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !maps.EqualFunc(assignment, want, slices.Equal) {
+					t.Errorf("unweighted assignment %v differs from distributeAnts %v", assignment, want)
+				}
+			}
+
+			result, err := DFSSolver{}.Solve(graph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateMoves(graph, result.Moves); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// checkParsed fails the test unless graph is a map that could be solved: a
+// positive ant count, a single start and a distinct single end, and tunnels
+// between rooms that exist.
+func checkParsed(t *testing.T, graph *Graph) {
+	t.Helper()
+	if err := graph.Validate(); err != nil {
+		t.Fatalf("parsed map is invalid: %v", err)
+	}
+	if graph.AntCount <= 0 {
+		t.Fatalf("parsed map has %d ants", graph.AntCount)
+	}
+	for name, room := range graph.Rooms {
+		if room.IsStart != (name == graph.StartRoom) || room.IsEnd != (name == graph.EndRoom) {
+			t.Fatalf("room %s is marked %+v, but the start is %q and the end %q", name, room, graph.StartRoom, graph.EndRoom)
+		}
+	}
+	for room, neighbors := range graph.Connections {
+		if _, ok := graph.Rooms[room]; !ok {
+			t.Fatalf("tunnels lead from unknown room %q", room)
+		}
+		for _, neighbor := range neighbors {
+			if _, ok := graph.Rooms[neighbor]; !ok || neighbor == room {
+				t.Fatalf("tunnel %s-%s leads to an unknown room or back", room, neighbor)
+			}
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, false)
+		f.Add(data, true)
+	}
+	f.Add([]byte("#format 1\n2\n##start\ns\t0.5 -1 2\n# kind:nest\n##end\ne 1 0\ns->e*2:3\n"), true)
+
+	f.Fuzz(func(t *testing.T, data []byte, extended bool) {
+		opts := parseOptions{}
+		if extended {
+			opts = parseOptions{lenient: true, floatCoords: true, tags: true, maxNameLength: 8}
+		}
+		graph, lines, err := parseMap(bytes.NewReader(data), opts)
+		if err != nil {
+			return
+		}
+		checkParsed(t, graph)
+		if len(lines) == 0 {
+			t.Fatal("parsed map has no lines")
+		}
+	})
+}
+
+func TestTwoStartRooms(t *testing.T) {
+	for text, want := range map[string]string{
+		"1\n##start\na 0 0\n##start\nb 1 0\n##end\ne 2 0\na-e\nb-e\n": "more than one start room: a and b",
+		"1\n##start\na 0 0\n##end\nb 1 0\n##end\ne 2 0\na-e\nb-e\n":   "more than one end room: b and e",
+	} {
+		_, _, err := parseMap(strings.NewReader(text), parseOptions{})
+		if err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %s", err, want)
+		}
+	}
+}
+
+func TestManyAntsReachEndTogether(t *testing.T) {
+	// Five rooms side by side between the start and the end, whose capacity
+	// of one is ignored: the end holds any number of ants.
+	var b strings.Builder
+	b.WriteString("5\n##start\ns 0 0\n##end\ne 2 0 1\n")
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&b, "r%d 1 %d\n", i, i)
+	}
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&b, "s-r%d\nr%d-e\n", i, i)
+	}
+	graph := mustParse(t, b.String(), parseOptions{})
+	if !graph.Unlimited(graph.EndRoom) {
+		t.Fatal("the end room is limited by its capacity")
+	}
+
+	for algo, solver := range testSolvers {
+		result, err := solver.Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Turns != 2 || len(result.Moves[1]) != 5 {
+			t.Errorf("%s: moves = %v, want all five ants to arrive in turn 2", algo, result.Moves)
+		}
+		if err := validateMoves(graph, result.Moves); err != nil {
+			t.Errorf("%s: %v", algo, err)
+		}
+		var buf bytes.Buffer
+		if err := visualizeAntMovements(&buf, graph, result.Moves, false); err != nil {
+			t.Errorf("%s: %v", algo, err)
+		}
+		if !strings.Contains(buf.String(), "Turn 2: L1-e L2-e L3-e L4-e L5-e\n  e: L1 L2 L3 L4 L5\n") {
+			t.Errorf("%s: visualizer does not show five ants in e:\n%s", algo, buf.String())
+		}
+	}
+}
+
+func TestStrictCoordinates(t *testing.T) {
+	for _, text := range []string{"+2", "01", "1.5", "0x10", "-0", "-", "1e3"} {
+		if n, err := parseCoordinate(text); err == nil {
+			t.Errorf("parseCoordinate(%q) = %d, want an error", text, n)
+		}
+		farm := "1\n##start\ns 0 0\n##end\ne " + text + " 0\ns-e\n"
+		if _, _, err := parseMap(strings.NewReader(farm), parseOptions{}); err == nil {
+			t.Errorf("a room at x=%s parsed", text)
+		}
+	}
+	for text, want := range map[string]int{"0": 0, "7": 7, "-12": -12, "100": 100} {
+		if n, err := parseCoordinate(text); err != nil || n != want {
+			t.Errorf("parseCoordinate(%q) = %d, %v, want %d", text, n, err, want)
+		}
+	}
+}
+
+func TestCommentAfterCommand(t *testing.T) {
+	graph := mustParse(t, "1\n##start\n# note\nroom 1 2\n##end\n#\n# another note\ne 3 4\nroom-e\n", parseOptions{})
+	checkParsed(t, graph)
+	if graph.StartRoom != "room" || !graph.Rooms["room"].IsStart {
+		t.Errorf("start room = %q, want room", graph.StartRoom)
+	}
+	if graph.EndRoom != "e" || !graph.Rooms["e"].IsEnd {
+		t.Errorf("end room = %q, want e", graph.EndRoom)
+	}
+	if room := graph.Rooms["room"]; room.X != 1 || room.Y != 2 {
+		t.Errorf("room at %d,%d, want 1,2", room.X, room.Y)
+	}
+}
+
+func TestIsolatedStartOrEnd(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, text, want string
+	}{
+		{"start", "1\n##start\ns 0 0\na 1 0\n##end\ne 2 0\na-e\n", "start room has no tunnels: s"},
+		{"end", "1\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\n", "end room has no tunnels: e"},
+		// A tunnel that only leads out of the end room cannot bring ants in.
+		{"end left one way", "1\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\ne->a\n", "end room has no tunnels: e"},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseMap(strings.NewReader(tt.text), parseOptions{}); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.want)
+		}
+
+		path := filepath.Join(dir, "farm.txt")
+		if err := os.WriteFile(path, []byte(tt.text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(os.Args[0], path)
+		cmd.Env = append(os.Environ(), runMainEnv+"=1")
+		output, err := cmd.CombinedOutput()
+		if err == nil || string(output) != "ERROR: "+tt.want+"\n" {
+			t.Errorf("%s: printed %q (%v), want ERROR: %s", tt.name, output, err, tt.want)
+		}
+	}
+}
+
+func TestTunnelWidth(t *testing.T) {
+	const farm = "4\n##start\ns 0 0\n##end\ne 1 0\ns-e*2\n"
+	graph := mustParse(t, farm, parseOptions{})
+	if width := graph.Width("s", "e"); width != 2 {
+		t.Fatalf("width of s-e = %d, want 2", width)
+	}
+	for algo, solver := range testSolvers {
+		result, err := solver.Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"L1-e", "L2-e"}, {"L3-e", "L4-e"}}
+		if !slices.EqualFunc(result.Moves, want, slices.Equal) {
+			t.Errorf("%s: moves = %v, want two ants across each turn", algo, result.Moves)
+		}
+	}
+
+	// Without the width one ant crosses per turn.
+	narrow := mustParse(t, strings.Replace(farm, "*2", "", 1), parseOptions{})
+	if width := narrow.Width("s", "e"); width != 1 {
+		t.Errorf("default width = %d, want 1", width)
+	}
+	if result, err := (DFSSolver{}).Solve(narrow); err != nil || result.Turns != 4 {
+		t.Errorf("narrow tunnel: %v turns (%v), want 4", result.Turns, err)
+	}
+
+	err := validateMoves(graph, [][]string{{"L1-e", "L2-e", "L3-e"}, {"L4-e"}})
+	if err == nil || err.Error() != "turn 1: tunnel s-e used by more ants than its width of 2" {
+		t.Errorf("three ants through a tunnel of width 2: err = %v", err)
+	}
+}
+
+func TestStrictCheck(t *testing.T) {
+	graph := mustParse(t, "2\n##start\ns 0 0\na 1 0\nb 1 1\n##end\ne 2 0\ns-a\na-e\ns-b\n", parseOptions{})
+	// A faulty assignment sends ant 2 from b to e, where no tunnel leads.
+	assignment := map[int][]string{1: {"s", "a", "e"}, 2: {"s", "b", "e"}}
+
+	var emitted [][]string
+	emit := func(moves []antMove) error {
+		emitted = append(emitted, moveStrings(moves))
+		return nil
+	}
+	if _, err := simulateAntMoves(graph, assignment, simOptions{}, emit); err != nil {
+		t.Fatalf("without -strict-check: %v", err)
+	}
+	if err := validateMoves(graph, emitted); err == nil {
+		t.Fatal("the faulty moves are valid")
+	}
+
+	emitted = nil
+	turns, err := simulateAntMoves(graph, assignment, simOptions{strict: true}, emit)
+	if want := "strict check failed: turn 2: no tunnel from b to e for ant 2"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+	// The check runs before the turn is emitted.
+	if turns != 2 || len(emitted) != 1 {
+		t.Errorf("failed after %d turns with %d emitted, want 2 and 1", turns, len(emitted))
+	}
+
+	if _, err := simulateAntMoves(graph, map[int][]string{1: {"s", "a", "e"}, 2: {"s", "a", "e"}}, simOptions{strict: true}, emit); err != nil {
+		t.Errorf("valid assignment: %v", err)
+	}
+}
+
+func TestLongLines(t *testing.T) {
+	// A room name of 100KB makes both its room line and its link line
+	// longer than bufio.Scanner's default limit of 64KB.
+	name := strings.Repeat("r", 100<<10)
+	graph := mustParse(t, "1\n##start\ns 0 0\n"+name+" 1 0\n##end\ne 2 0\ns-"+name+"\n"+name+"-e\n", parseOptions{})
+	if path := findShortestPath(graph); len(path) != 3 || path[1] != name {
+		t.Errorf("path through the long room has %d rooms", len(path))
+	}
+
+	tooLong := "1\n##start\ns 0 0\n##end\ne 1 0\n#" + strings.Repeat("x", maxLineLength) + "\ns-e\n"
+	_, _, err := parseMap(strings.NewReader(tooLong), parseOptions{})
+	if want := fmt.Sprintf("line 6 is longer than %d bytes", maxLineLength); err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+}
+
+func TestRoomTags(t *testing.T) {
+	const farm = `2
+# tag:water
+# depth: 3
+##start
+s 0 0
+# not a tag
+a 1 0
+# tag:dry
+s-a
+##end
+e 2 0
+a-e
+`
+	graph := mustParse(t, farm, parseOptions{tags: true})
+	if want := map[string]string{"tag": "water", "depth": "3"}; !maps.Equal(graph.Rooms["s"].Tags, want) {
+		t.Errorf("tags of s = %v, want %v", graph.Rooms["s"].Tags, want)
+	}
+	// A tag belongs to the next room only, and a link in between drops it.
+	for _, name := range []string{"a", "e"} {
+		if tags := graph.Rooms[name].Tags; tags != nil {
+			t.Errorf("tags of %s = %v, want none", name, tags)
+		}
+	}
+	if plain := mustParse(t, farm, parseOptions{}); roomTags(plain) != nil {
+		t.Errorf("tags collected without -tags: %v", roomTags(plain))
+	}
+
+	stdout, results := solveText(t, farm, options{noEcho: true, parse: parseOptions{tags: true}})
+	if !strings.Contains(stdout, "Room tags:\ns: depth=3, tag=water\n") {
+		t.Errorf("-tags printed:\n%s", stdout)
+	}
+	if _, plain := solveText(t, farm, options{noEcho: true}); results != plain {
+		t.Errorf("tags changed the moves:\n%s\nwant:\n%s", results, plain)
+	}
+}
+
+func TestDuplicateRoom(t *testing.T) {
+	graph := NewGraph()
+	if err := graph.AddRoom("s", 0, 0, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.AddRoom("s", 5, 5, false, false); err == nil || err.Error() != "duplicate room: s" {
+		t.Errorf("adding s twice: err = %v", err)
+	}
+	// The first definition, start flag included, survives.
+	if room := graph.Rooms["s"]; !room.IsStart || room.X != 0 || graph.StartRoom != "s" {
+		t.Errorf("room s = %+v, start room %q after the duplicate", room, graph.StartRoom)
+	}
+
+	for _, text := range []string{
+		"1\n##start\ns 0 0\n##end\ne 1 0\ns 2 0\ns-e\n",
+		"1\n##start\ns 0 0\ne 1 0\n##end\ne 2 0\ns-e\n",
+	} {
+		_, _, err := parseMap(strings.NewReader(text), parseOptions{})
+		if err == nil || !strings.HasPrefix(err.Error(), "duplicate room: ") {
+			t.Errorf("%q: err = %v, want a duplicate room", text, err)
+		}
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	graph, _, err := readInput(server.URL+"/example01.txt", parseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := readExample(t, "example01.txt").Diff(graph); diff != nil {
+		t.Errorf("fetched map differs from the file: %v", diff)
+	}
+
+	opts, err := parseArgs([]string{"-no-echo", server.URL + "/example00.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := captureStdout(t, func() error { return run(opts) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "L1-2\nL1-3 L2-2\nL1-1 L2-3 L3-2\nL2-1 L3-3 L4-2\nL3-1 L4-3\nL4-1\n"; !strings.HasPrefix(stdout, want) {
+		t.Errorf("solving the fetched map printed:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	_, _, err = readInput(server.URL+"/missing.txt", parseOptions{})
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("fetching a missing map: err = %v", err)
+	}
+}
+
+func TestReadURLTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := "#" + strings.Repeat("x", 1<<20-2) + "\n"
+		fmt.Fprint(w, "1\n")
+		for written := 0; written <= maxURLBytes; written += len(line) {
+			if _, err := io.WriteString(w, line); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	_, _, err := readInput(server.URL, parseOptions{})
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != maxURLBytes {
+		t.Errorf("fetching more than %d bytes: err = %v", maxURLBytes, err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// moveWriter writes turns of moves, one turn per line, e.g. "L1-a L2-b".
+type moveWriter struct {
+	w       *bufio.Writer
+	indexed bool // prefix each line with "Turn N: "
+	ndjson  bool // write each turn as a JSON object
+	// padding, when positive, zero-pads the ant numbers to that many
+	// digits, e.g. "L0001-a", so the moves line up.
+	padding int
+	// flushEvery, when positive, flushes the output after every flushEvery
+	// turns, trading latency for fewer writes. JSON output is otherwise
+	// flushed after every turn, and text output only when the caller does.
+	flushEvery int
+	turn       int
+}
+
+// ndjsonTurn is the JSON form of one turn of moves.
+type ndjsonTurn struct {
+	Turn  int      `json:"turn"`
+	Moves []string `json:"moves"`
+}
+
+// writeTurn writes the moves made in the next turn, e.g. "L1-a".
+func (mw *moveWriter) writeTurn(moves []string) error {
+	mw.turn++
+	if mw.padding > 0 {
+		padded := make([]string, len(moves))
+		for i, move := range moves {
+			padded[i] = padMove(move, mw.padding)
+		}
+		moves = padded
+	}
+	if err := mw.writeMoves(moves); err != nil {
+		return err
+	}
+	every := mw.flushEvery
+	if every <= 0 && mw.ndjson {
+		every = 1
+	}
+	if every > 0 && mw.turn%every == 0 {
+		return mw.w.Flush()
+	}
+	return nil
+}
+
+// padMove zero-pads the ant number of a move such as "L1-a" to width digits.
+func padMove(move string, width int) string {
+	ant, room, _ := strings.Cut(strings.TrimPrefix(move, "L"), "-")
+	if len(ant) >= width {
+		return move
+	}
+	return "L" + strings.Repeat("0", width-len(ant)) + ant + "-" + room
+}
+
+// writeMoves writes a turn in the configured format.
+func (mw *moveWriter) writeMoves(moves []string) error {
+	if mw.ndjson {
+		return mw.writeJSON(moves)
+	}
+	if mw.indexed {
+		if _, err := fmt.Fprintf(mw.w, "Turn %d: ", mw.turn); err != nil {
+			return err
+		}
+	}
+	for i, move := range moves {
+		if i > 0 {
+			if _, err := io.WriteString(mw.w, " "); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(mw.w, move); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(mw.w, "\n")
+	return err
+}
+
+// writeJSON writes the turn as a line of JSON. Unless flushEvery says
+// otherwise, writeTurn flushes it at once, so a consumer reading the stream
+// sees every turn as soon as it is computed.
+func (mw *moveWriter) writeJSON(moves []string) error {
+	line, err := json.Marshal(ndjsonTurn{Turn: mw.turn, Moves: moves})
+	if err != nil {
+		return err
+	}
+	_, err = mw.w.Write(append(line, '\n'))
+	return err
+}
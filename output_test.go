@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIndexedOutput(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "example00.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const plain = `L1-2
+L1-3 L2-2
+L1-1 L2-3 L3-2
+L2-1 L3-3 L4-2
+L3-1 L4-3
+L4-1
+`
+	const indexed = `Turn 1: L1-2
+Turn 2: L1-3 L2-2
+Turn 3: L1-1 L2-3 L3-2
+Turn 4: L2-1 L3-3 L4-2
+Turn 5: L3-1 L4-3
+Turn 6: L4-1
+`
+	tests := []struct {
+		name string
+		opts options
+		want string
+	}{
+		{"plain", options{}, plain},
+		{"indexed", options{indexed: true}, indexed},
+	}
+	for _, tt := range tests {
+		tt.opts.noEcho = true
+		_, results := solveText(t, string(data), tt.opts)
+		if results != tt.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tt.name, results, tt.want)
+		}
+	}
+}
+
+func TestNoEcho(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "example00.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const moves = "L1-2\nL1-3 L2-2\nL1-1 L2-3 L3-2\nL2-1 L3-3 L4-2\nL3-1 L4-3\nL4-1\n"
+
+	_, results := solveText(t, string(data), options{})
+	if want := strings.TrimSuffix(string(data), "\n") + "\n\n" + moves; results != want {
+		t.Errorf("output:\n%s\nwant the map, a blank line and the moves", results)
+	}
+
+	stdout, results := solveText(t, string(data), options{noEcho: true, verbose: true})
+	if results != moves {
+		t.Errorf("-no-echo output:\n%s\nwant only the moves:\n%s", results, moves)
+	}
+	if !strings.Contains(stdout, "Critical path: 3 moves of 6 turns\n") {
+		t.Errorf("-no-echo dropped the turn summary:\n%s", stdout)
+	}
+	if strings.Contains(stdout+results, "##start") {
+		t.Error("-no-echo printed the map")
+	}
+}
+
+func TestPaddedOutput(t *testing.T) {
+	for _, tt := range []struct {
+		ants, width int
+		first       string
+	}{
+		{9, 1, "L1-e"},
+		{10, 2, "L01-e"},
+		{1000, 4, "L0001-e"},
+	} {
+		farm := fmt.Sprintf("%d\n##start\ns 0 0\n##end\ne 1 0\ns-e\n", tt.ants)
+		_, results := solveText(t, farm, options{noEcho: true, padded: true})
+		moves := strings.Fields(results)
+		if len(moves) != tt.ants || moves[0] != tt.first {
+			t.Errorf("%d ants: %d moves starting %q, want %d starting %q", tt.ants, len(moves), moves[0], tt.ants, tt.first)
+			continue
+		}
+		for _, move := range moves {
+			if ant, _, _ := strings.Cut(strings.TrimPrefix(move, "L"), "-"); len(ant) != tt.width {
+				t.Errorf("%d ants: move %s is not padded to %d digits", tt.ants, move, tt.width)
+				break
+			}
+		}
+		if !slices.IsSorted(moves) {
+			t.Errorf("%d ants: padded moves do not sort in ant order", tt.ants)
+		}
+
+		turns, err := readMoves(strings.NewReader(results))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validateMoves(mustParse(t, farm, parseOptions{}), turns); err != nil {
+			t.Errorf("%d ants: padded moves do not replay: %v", tt.ants, err)
+		}
+	}
+
+	if got := padMove("L12345-room", 3); got != "L12345-room" {
+		t.Errorf("padMove of a wider ant number = %q", got)
+	}
+}
+
+func TestNDJSONOutput(t *testing.T) {
+	graph := readExample(t, "example01.txt")
+	want, err := DFSSolver{}.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "example01.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, results := solveText(t, string(data), options{ndjson: true})
+	lines := strings.Split(strings.TrimSuffix(results, "\n"), "\n")
+	var moves [][]string
+	for i, line := range lines {
+		// Each line stands on its own.
+		var turn ndjsonTurn
+		if err := json.Unmarshal([]byte(line), &turn); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if turn.Turn != i+1 {
+			t.Errorf("line %d holds turn %d", i+1, turn.Turn)
+		}
+		moves = append(moves, turn.Moves)
+	}
+	if !slices.EqualFunc(moves, want.Moves, slices.Equal) {
+		t.Errorf("decoded moves = %v, want %v", moves, want.Moves)
+	}
+}
+
+// countingWriter counts the writes made to it.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestNDJSONFlushesEachTurn(t *testing.T) {
+	var w countingWriter
+	out := &moveWriter{w: bufio.NewWriter(&w), ndjson: true}
+	for i := 1; i <= 3; i++ {
+		if err := out.writeTurn([]string{fmt.Sprintf("L%d-a", i)}); err != nil {
+			t.Fatal(err)
+		}
+		if w.writes != i {
+			t.Errorf("after turn %d: %d writes, want %d", i, w.writes, i)
+		}
+	}
+	want := `{"turn":1,"moves":["L1-a"]}` + "\n" + `{"turn":2,"moves":["L2-a"]}` + "\n" + `{"turn":3,"moves":["L3-a"]}` + "\n"
+	if w.String() != want {
+		t.Errorf("wrote:\n%s\nwant:\n%s", w.String(), want)
+	}
+}
+
+func TestFlushEvery(t *testing.T) {
+	for _, ndjson := range []bool{false, true} {
+		var w countingWriter
+		out := &moveWriter{w: bufio.NewWriter(&w), ndjson: ndjson, flushEvery: 5}
+		for i := 1; i <= 12; i++ {
+			if err := out.writeTurn([]string{fmt.Sprintf("L%d-a", i)}); err != nil {
+				t.Fatal(err)
+			}
+			// Only whole batches of five turns reach the writer.
+			if want := i / 5; w.writes != want {
+				t.Errorf("ndjson %v, after turn %d: %d writes, want %d", ndjson, i, w.writes, want)
+			}
+			if want := i - i%5; strings.Count(w.String(), "\n") != want {
+				t.Errorf("ndjson %v, after turn %d: %d turns written, want %d", ndjson, i, strings.Count(w.String(), "\n"), want)
+			}
+		}
+		if err := out.w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if w.writes != 3 || strings.Count(w.String(), "\n") != 12 {
+			t.Errorf("ndjson %v: %d writes of %d turns after the final flush, want 3 of 12", ndjson, w.writes, strings.Count(w.String(), "\n"))
+		}
+	}
+}
+
+func TestPathsOutput(t *testing.T) {
+	const farm = `4
+##start
+s 0 0
+a 1 0
+b 1 1
+c 2 1
+##end
+e 3 0
+s-a
+a-e
+s-b
+b-c
+c-e`
+	// The map, the chosen paths shortest first, then the moves, each block
+	// followed by a blank line.
+	want := farm + `
+
+s a e
+s b c e
+
+L1-a L3-b
+L1-e L2-a L3-c
+L2-e L3-e L4-a
+L4-e
+`
+	for run := 0; run < 3; run++ {
+		if _, results := solveText(t, farm, options{paths: true}); results != want {
+			t.Fatalf("output:\n%s\nwant:\n%s", results, want)
+		}
+	}
+
+	// Streams meant for programs carry the moves alone.
+	_, results := solveText(t, farm, options{paths: true, ndjson: true})
+	if !strings.HasPrefix(results, `{"turn":1,`) {
+		t.Errorf("-ndjson output starts with something other than the moves:\n%s", results)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "solution.txt")
+	opts, err := parseArgs([]string{"-v", "-output", file, filepath.Join("testdata", "example00.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := captureStdout(t, func() error { return run(opts) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `L1-2
+L1-3 L2-2
+L1-1 L2-3 L3-2
+L2-1 L3-3 L4-2
+L3-1 L4-3
+L4-1
+# turns: 6
+`
+	if string(data) != want {
+		t.Errorf("output file holds:\n%s\nwant:\n%s", data, want)
+	}
+	// The file can be replayed as it is.
+	turns, err := readMoves(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMoves(readExample(t, "example00.txt"), turns); err != nil {
+		t.Error(err)
+	}
+
+	// Status and debug text stay on stdout, without the map or the moves.
+	if !strings.Contains(stdout, "Number of ants: 4\n") || strings.Contains(stdout, "L1-2") || strings.Contains(stdout, "##start") {
+		t.Errorf("stdout:\n%s", stdout)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// findAllPathsParallel finds the same paths as findAllPaths, but explores the
+// subtree behind each neighbor of the start room in its own goroutine, with at
+// most workers goroutines running at once. The paths are returned sorted by
+// their room names so the result does not depend on scheduling.
+func findAllPathsParallel(graph *Graph, start string, maxRooms, workers int) [][]string {
+	if start == graph.EndRoom || (maxRooms > 0 && maxRooms < 2) {
+		var allPaths [][]string
+		findAllPaths(graph, start, make(map[string]bool), []string{}, &allPaths, maxRooms)
+		return allPaths
+	}
+
+	neighbors := make(chan string)
+	results := make(chan [][]string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for neighbor := range neighbors {
+				var paths [][]string
+				visited := map[string]bool{start: true}
+				findAllPaths(graph, neighbor, visited, []string{start}, &paths, maxRooms)
+				results <- paths
+			}
+		}()
+	}
+	go func() {
+		for _, neighbor := range graph.Connections[start] {
+			neighbors <- neighbor
+		}
+		close(neighbors)
+		wg.Wait()
+		close(results)
+	}()
+
+	var allPaths [][]string
+	for paths := range results {
+		allPaths = append(allPaths, paths...)
+	}
+	slices.SortFunc(allPaths, slices.Compare)
+	return allPaths
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestFindAllPathsParallel(t *testing.T) {
+	graphs := map[string]*Graph{"grid": mustParse(t, gridMap(4, 3, 1), parseOptions{})}
+	for _, name := range exampleMaps {
+		graphs[name] = readExample(t, name)
+	}
+	for name, graph := range graphs {
+		for _, maxRooms := range []int{0, 1, 2, 6} {
+			var want [][]string
+			findAllPaths(graph, graph.StartRoom, make(map[string]bool), []string{}, &want, maxRooms)
+			slices.SortFunc(want, slices.Compare)
+			for _, workers := range []int{1, 2, 8} {
+				got := findAllPathsParallel(graph, graph.StartRoom, maxRooms, workers)
+				if !slices.EqualFunc(got, want, slices.Equal) {
+					t.Errorf("%s with %d rooms and %d workers: %d paths, want the %d found sequentially", name, maxRooms, workers, len(got), len(want))
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkFindAllPaths(b *testing.B) {
+	graph := mustParse(b, gridMap(5, 4, 1), parseOptions{})
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var paths [][]string
+			findAllPaths(graph, graph.StartRoom, make(map[string]bool), []string{}, &paths, 0)
+		}
+	})
+	for _, workers := range []int{2, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findAllPathsParallel(graph, graph.StartRoom, 0, workers)
+			}
+		})
+	}
+}
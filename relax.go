@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// maxRelaxCandidates caps how many paths relaxPaths tries adding, since each
+// try simulates the whole run.
+const maxRelaxCandidates = 64
+
+// relaxPaths is the experimental -relax heuristic. The solvers only spread
+// the ants over paths that share no room, which wastes rooms that hold more
+// than one ant and can leave the group well short of what the map allows.
+// When the group needs more turns than lowerBoundTurns, relaxPaths tries
+// adding candidate paths that do share rooms with it, shortest first, and
+// keeps each one that lets the ants finish in fewer turns. The turns are
+// counted by simulating the run, since estimateTurns assumes the paths never
+// meet; a candidate whose ants would block each other for good is dropped.
+// It stops at the bound, after maxRelaxCandidates tries, or when the
+// candidates run out, and returns the paths with the turns they need.
+func relaxPaths(graph *Graph, group, candidates [][]string, logger *slog.Logger) ([][]string, int, error) {
+	turns, err := simulatedTurns(graph, group)
+	if err != nil {
+		return nil, 0, err
+	}
+	bound := lowerBoundTurns(graph, graph.AntCount)
+	logger.Debug("relaxing", "turns", turns, "bound", bound)
+
+	tried := 0
+	for _, candidate := range candidates {
+		if turns <= bound || tried == maxRelaxCandidates {
+			break
+		}
+		if slices.ContainsFunc(group, func(path []string) bool { return slices.Equal(path, candidate) }) {
+			continue
+		}
+		tried++
+		relaxed := append(slices.Clone(group), candidate)
+		relaxedTurns, err := simulatedTurns(graph, relaxed)
+		if err != nil || relaxedTurns >= turns {
+			continue
+		}
+		logger.Debug("path added", "path", candidate, "turns", relaxedTurns)
+		group, turns = relaxed, relaxedTurns
+	}
+	return group, turns, nil
+}
+
+// simulatedTurns spreads the ants over the paths and returns how many turns
+// the simulated run takes.
+func simulatedTurns(graph *Graph, paths [][]string) (int, error) {
+	assignment, err := distributeAntsByCost(graph, paths, graph.AntCount)
+	if err != nil {
+		return 0, err
+	}
+	return simulateAntMoves(graph, assignment, simOptions{}, func([]antMove) error { return nil })
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// sharedRoomMap has two routes from the start that meet in m, a room that
+// holds two ants and has a tunnel to the end two ants wide.
+const sharedRoomMap = `10
+##start
+s 0 0
+x 1 0
+y 1 1
+m 2 0 2
+##end
+e 3 0
+s-x
+s-y
+x-m
+y-m
+m-e*2
+`
+
+func TestRelaxPaths(t *testing.T) {
+	graph := mustParse(t, sharedRoomMap, parseOptions{})
+	strict, err := DFSSolver{}.ChoosePaths(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strict) != 1 {
+		t.Fatalf("disjoint paths = %v, want one", strict)
+	}
+	strictTurns, err := simulatedTurns(graph, strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1)
+	relaxed, turns, err := relaxPaths(graph, strict, candidates, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relaxed) != 2 || turns >= strictTurns {
+		t.Errorf("relaxed to %v in %d turns, want both paths in fewer than %d", relaxed, turns, strictTurns)
+	}
+
+	_, strictResults := solveText(t, sharedRoomMap, options{noEcho: true})
+	_, relaxedResults := solveText(t, sharedRoomMap, options{noEcho: true, relax: true})
+	if got, want := strings.Count(strictResults, "\n"), strictTurns; got != want {
+		t.Errorf("strict run took %d turns, want %d", got, want)
+	}
+	if got := strings.Count(relaxedResults, "\n"); got != turns {
+		t.Errorf("-relax run took %d turns, want %d", got, turns)
+	}
+	var moves [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(relaxedResults, "\n"), "\n") {
+		moves = append(moves, strings.Fields(line))
+	}
+	if err := validateMoves(graph, moves); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRelaxStopsAtBound(t *testing.T) {
+	// example00 has a single path, which already meets the lower bound.
+	graph := readExample(t, "example00.txt")
+	group := [][]string{findShortestPath(graph)}
+	relaxed, turns, err := relaxPaths(graph, group, findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1), discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relaxed) != 1 || turns != lowerBoundTurns(graph, graph.AntCount) {
+		t.Errorf("relaxed to %v in %d turns", relaxed, turns)
+	}
+}
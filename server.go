@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxRequestBytes limits the size of a map posted to the server.
+	maxRequestBytes = 1 << 20
+	// maxRequestAnts limits the ants of a map posted to the server, since
+	// the response lists every move of every ant.
+	maxRequestAnts = 100000
+	// solveTimeout limits how long the server waits on a single request
+	// before answering that it timed out. The solve itself cannot be
+	// stopped, so serverSolver keeps it polynomial.
+	solveTimeout = 10 * time.Second
+)
+
+// serverSolver returns the solver the server runs in place of solver.
+// DFSSolver enumerates every path, which on a hostile map never ends, so it
+// is replaced by a DinicSolver with the same limits; the flow solvers are
+// kept as they are.
+func serverSolver(solver Solver) Solver {
+	if dfs, ok := solver.(DFSSolver); ok {
+		return DinicSolver{MaxPaths: dfs.MaxPaths, Logger: dfs.Logger}
+	}
+	return solver
+}
+
+// solveResponse is the JSON body returned by the /solve endpoint.
+type solveResponse struct {
+	Paths [][]string   `json:"paths"`
+	Turns int          `json:"turns"`
+	Moves []ndjsonTurn `json:"moves"`
+	// Tags holds the tags of each tagged room when the server collects
+	// them.
+	Tags map[string]map[string]string `json:"tags,omitempty"`
+}
+
+// errorResponse is the JSON body returned when a request fails.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// newServer returns the HTTP handler for -serve. POST /solve takes a map in
+// the request body and responds with its solution as a solveResponse. Maps
+// are solved with serverSolver(solver).
+func newServer(solver Solver, opts parseOptions) http.Handler {
+	solver = serverSolver(solver)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /solve", func(w http.ResponseWriter, r *http.Request) {
+		graph, _, err := parseMap(http.MaxBytesReader(w, r.Body, maxRequestBytes), opts)
+		if err == nil && graph.AntCount > maxRequestAnts {
+			err = fmt.Errorf("too many ants: %d (at most %d)", graph.AntCount, maxRequestAnts)
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeJSONResponse(w, status, errorResponse{Error: err.Error()})
+			return
+		}
+		result, err := solver.Solve(graph)
+		if err != nil {
+			writeJSONResponse(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+			return
+		}
+
+		response := solveResponse{Paths: result.Paths, Turns: result.Turns, Moves: make([]ndjsonTurn, len(result.Moves)), Tags: roomTags(graph)}
+		for i, moves := range result.Moves {
+			response.Moves[i] = ndjsonTurn{Turn: i + 1, Moves: moves}
+		}
+		writeJSONResponse(w, http.StatusOK, response)
+	})
+	return http.TimeoutHandler(mux, solveTimeout, `{"error":"solve timed out"}`)
+}
+
+// writeJSONResponse writes body as JSON with the given status code.
+func writeJSONResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// serve answers solve requests on addr until the server fails.
+func serve(addr string, solver Solver, opts parseOptions) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newServer(solver, opts),
+		ReadHeaderTimeout: solveTimeout,
+	}
+	return server.ListenAndServe()
+}
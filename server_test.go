@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	server := httptest.NewServer(newServer(DFSSolver{}, parseOptions{}))
+	defer server.Close()
+	post := func(body string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/solve", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var data json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		return resp, data
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "example00.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := post(string(data))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q: %s", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+	want := `{"paths":[["0","2","3","1"]],"turns":6,"moves":[` +
+		`{"turn":1,"moves":["L1-2"]},` +
+		`{"turn":2,"moves":["L1-3","L2-2"]},` +
+		`{"turn":3,"moves":["L1-1","L2-3","L3-2"]},` +
+		`{"turn":4,"moves":["L2-1","L3-3","L4-2"]},` +
+		`{"turn":5,"moves":["L3-1","L4-3"]},` +
+		`{"turn":6,"moves":["L4-1"]}]}`
+	if string(body) != want {
+		t.Errorf("response:\n%s\nwant:\n%s", body, want)
+	}
+
+	for _, tt := range []struct {
+		name, body string
+		status     int
+		err        string
+	}{
+		{"bad map", "0\n", http.StatusBadRequest, "invalid number of ants"},
+		{"too many ants", fmt.Sprintf("%d\n##start\ns 0 0\n##end\ne 1 0\ns-e\n", maxRequestAnts+1), http.StatusBadRequest, "too many ants: 100001 (at most 100000)"},
+		{"no path", "1\n##start\ns 0 0\na 1 0\nb 2 0\n##end\ne 3 0\ns-a\nb-e\n", http.StatusUnprocessableEntity, "no valid path found"},
+		{"too large", "1\n" + strings.Repeat("#", maxRequestBytes) + "\n", http.StatusRequestEntityTooLarge, "http: request body too large"},
+	} {
+		resp, body := post(tt.body)
+		var got errorResponse
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status || got.Error != tt.err {
+			t.Errorf("%s: status %d, error %q; want %d, %q", tt.name, resp.StatusCode, got.Error, tt.status, tt.err)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/solve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /solve: status %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServerSolver(t *testing.T) {
+	// Enumerating every path of a request's map could run forever, so the
+	// depth-first solver is replaced by Dinic's with the same cap.
+	got := serverSolver(DFSSolver{MaxPaths: 3, Search: "bfs"})
+	if want := (DinicSolver{MaxPaths: 3}); got != want {
+		t.Errorf("serverSolver(dfs) = %#v, want %#v", got, want)
+	}
+	for _, solver := range []Solver{FlowSolver{MaxPaths: 2}, DinicSolver{}} {
+		if got := serverSolver(solver); got != solver {
+			t.Errorf("serverSolver(%#v) = %#v, want it unchanged", solver, got)
+		}
+	}
+
+	// A map whose paths are too many to enumerate is still solved at once.
+	graph := mustParse(t, gridMap(8, 8, 20), parseOptions{})
+	result, err := serverSolver(DFSSolver{}).Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMoves(graph, result.Moves); err != nil {
+		t.Error(err)
+	}
+	if !slices.ContainsFunc(result.Paths, func(path []string) bool { return len(path) == 10 }) {
+		t.Errorf("no shortest path among %v", result.Paths)
+	}
+}
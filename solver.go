@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+)
+
+// SolveResult is the outcome of solving a map.
+type SolveResult struct {
+	Paths [][]string // the paths the ants were spread across
+	Moves [][]string // the moves made in each turn, e.g. "L1-room"
+	Turns int
+}
+
+// Solver is a strategy for moving the ants from the start room to the end.
+// Strategies differ in how they choose the paths; the ants are then spread
+// across those paths and moved the same way. To use the solver as a library,
+// call Solve, or Solve on one of the strategies; the CLI streams the moves
+// instead of collecting them.
+type Solver interface {
+	// ChoosePaths returns the disjoint paths the ants should travel along.
+	ChoosePaths(g *Graph) ([][]string, error)
+	// Solve chooses the paths and moves the ants along them.
+	Solve(g *Graph) (SolveResult, error)
+}
+
+// newSolver returns the solver selected on the command line, logging to
+// logger.
+func newSolver(opts options, logger *slog.Logger) (Solver, error) {
+	switch opts.algo {
+	case "dfs":
+		if !slices.Contains(pathSearches, opts.search) {
+			return nil, fmt.Errorf("unknown paths algorithm: %s", opts.search)
+		}
+		return DFSSolver{Search: opts.search, Dedupe: opts.dedupe, MaxDetour: opts.detour, Workers: opts.parallel, MaxPaths: opts.maxPaths, Logger: logger}, nil
+	case "flow":
+		return FlowSolver{MaxPaths: opts.maxPaths, Logger: logger}, nil
+	case "dinic":
+		return DinicSolver{MaxPaths: opts.maxPaths, Logger: logger}, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm: %s", opts.algo)
+}
+
+// discardLogger stands in for the logger of a solver that was given none.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// orDiscard returns logger, or discardLogger if it is nil.
+func orDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+// Solve solves the graph with the default DFSSolver.
+func Solve(graph *Graph) (SolveResult, error) {
+	return DFSSolver{}.Solve(graph)
+}
+
+// solveWith chooses the paths with solver and moves the ants along them.
+func solveWith(solver Solver, graph *Graph) (SolveResult, error) {
+	paths, err := solver.ChoosePaths(graph)
+	if err != nil {
+		return SolveResult{}, err
+	}
+	assignment, err := distributeAntsByCost(graph, paths, graph.AntCount)
+	if err != nil {
+		return SolveResult{}, err
+	}
+	moves, err := getAntMoves(graph, assignment)
+	if err != nil {
+		return SolveResult{}, err
+	}
+	return SolveResult{Paths: paths, Moves: moves, Turns: len(moves)}, nil
+}
+
+// DFSSolver enumerates every simple path, depth-first by default, and picks
+// the group of disjoint paths that needs the fewest turns.
+type DFSSolver struct {
+	// Search names how the paths are enumerated: "dfs" (the default) or
+	// "bfs". Both find the same paths.
+	Search string
+	// Dedupe drops the paths dedupePaths finds can never help before
+	// grouping, so fewer groups are built.
+	Dedupe bool
+	// MaxDetour, when positive, skips paths with more than MaxDetour rooms
+	// beyond the shortest path. Such paths rarely help and pruning them cuts
+	// both the search and the grouping on large maps.
+	MaxDetour int
+	// Workers, when above one, enumerates the paths in up to that many
+	// goroutines, one subtree of the start room at a time.
+	Workers int
+	// MaxPaths, when positive, caps how many paths the ants are spread across.
+	MaxPaths int
+	// Logger, when set, receives debug records of the paths found and the
+	// group chosen.
+	Logger *slog.Logger
+}
+
+// ChoosePaths implements Solver.
+func (s DFSSolver) ChoosePaths(graph *Graph) ([][]string, error) {
+	maxRooms := 0
+	if s.MaxDetour > 0 {
+		shortest := findShortestPath(graph)
+		if shortest == nil {
+			return nil, fmt.Errorf("no valid path found")
+		}
+		maxRooms = len(shortest) + s.MaxDetour
+	}
+
+	logger := orDiscard(s.Logger)
+	paths := findShortestPaths(graph, graph.StartRoom, s.Search, maxRooms, s.Workers)
+	logger.Debug("paths found", "paths", len(paths))
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no valid path found")
+	}
+	if s.Dedupe {
+		paths = dedupePaths(graph, paths)
+		logger.Debug("paths deduplicated", "paths", len(paths))
+	}
+
+	solutionGroups := calculateSolutionGroups(paths, graph.StartRoom, graph.EndRoom)
+	if len(solutionGroups) == 0 {
+		return nil, fmt.Errorf("no compatible solution group found")
+	}
+	best := selectBestGroup(solutionGroups, graph.AntCount, s.MaxPaths)
+	logger.Debug("group chosen", "groups", len(solutionGroups), "paths", len(best), "turns", estimateTurns(best, graph.AntCount))
+	return best, nil
+}
+
+// Solve implements Solver.
+func (s DFSSolver) Solve(graph *Graph) (SolveResult, error) {
+	return solveWith(s, graph)
+}
+
+// FlowSolver finds vertex-disjoint paths as a maximum flow, adding one
+// augmenting path at a time and keeping the set of paths that needs the
+// fewest turns. Unlike DFSSolver it never enumerates every path, so it copes
+// with maps that have a very large number of routes.
+type FlowSolver struct {
+	// MaxPaths, when positive, caps how many paths the ants are spread across.
+	MaxPaths int
+	// Logger, when set, receives debug records of each augmenting path, the
+	// paths found and the paths chosen.
+	Logger *slog.Logger
+}
+
+// ChoosePaths implements Solver.
+func (s FlowSolver) ChoosePaths(graph *Graph) ([][]string, error) {
+	network := newFlowNetwork(graph)
+	source, sink := network.out(graph.StartRoom), network.in(graph.EndRoom)
+
+	logger := orDiscard(s.Logger)
+	var best [][]string
+	bestTurns, found := 0, 0
+	for network.augment(source, sink) {
+		paths := network.paths(source, sink)
+		if s.MaxPaths > 0 && len(paths) > s.MaxPaths {
+			break
+		}
+		found = len(paths)
+		sortPaths(paths)
+		turns := estimateTurns(paths, graph.AntCount)
+		logger.Debug("path augmented", "paths", len(paths), "turns", turns)
+		// Like selectBestGroup, prefer more paths when the turns are equal.
+		if best == nil || turns <= bestTurns {
+			best, bestTurns = paths, turns
+		}
+	}
+	logger.Debug("paths found", "paths", found)
+	if best == nil {
+		return nil, fmt.Errorf("no valid path found")
+	}
+	logger.Debug("group chosen", "paths", len(best), "turns", bestTurns)
+	return best, nil
+}
+
+// Solve implements Solver.
+func (s FlowSolver) Solve(graph *Graph) (SolveResult, error) {
+	return solveWith(s, graph)
+}
+
+// DinicSolver finds the largest set of vertex-disjoint paths with Dinic's
+// algorithm and uses as many of the shortest of them as needs the fewest
+// turns. It searches far less than FlowSolver on large maps, but since it
+// only considers subsets of the final paths it may settle for more turns.
+type DinicSolver struct {
+	// MaxPaths, when positive, caps how many paths the ants are spread across.
+	// When the maximum flow has more paths than that, the paths are chosen
+	// as FlowSolver chooses them, since a few paths of the flow are not
+	// necessarily the shortest.
+	MaxPaths int
+	// Logger, when set, receives debug records of the maximum flow and the
+	// paths chosen.
+	Logger *slog.Logger
+}
+
+// ChoosePaths implements Solver.
+func (s DinicSolver) ChoosePaths(graph *Graph) ([][]string, error) {
+	network := newFlowNetwork(graph)
+	source, sink := network.out(graph.StartRoom), network.in(graph.EndRoom)
+	logger := orDiscard(s.Logger)
+	flow := network.maxFlow(source, sink)
+	logger.Debug("paths found", "paths", flow)
+	if s.MaxPaths > 0 && flow > s.MaxPaths {
+		return FlowSolver{MaxPaths: s.MaxPaths, Logger: s.Logger}.ChoosePaths(graph)
+	}
+	paths := network.paths(source, sink)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no valid path found")
+	}
+	sortPaths(paths)
+
+	best, bestTurns := 0, 0
+	for count := 1; count <= len(paths); count++ {
+		// Like selectBestGroup, prefer more paths when the turns are equal.
+		if turns := estimateTurns(paths[:count], graph.AntCount); best == 0 || turns <= bestTurns {
+			best, bestTurns = count, turns
+		}
+	}
+	logger.Debug("group chosen", "paths", best, "turns", bestTurns)
+	return paths[:best], nil
+}
+
+// Solve implements Solver.
+func (s DinicSolver) Solve(graph *Graph) (SolveResult, error) {
+	return solveWith(s, graph)
+}
+
+// sortPaths orders paths shortest first, breaking ties by room names.
+func sortPaths(paths [][]string) {
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return slices.Compare(paths[i], paths[j]) < 0
+	})
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	graph := readExample(t, "example00.txt")
+	result, err := Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantPaths := [][]string{{"0", "2", "3", "1"}}
+	if !slices.EqualFunc(result.Paths, wantPaths, slices.Equal) {
+		t.Errorf("paths = %v, want %v", result.Paths, wantPaths)
+	}
+	wantMoves := [][]string{
+		{"L1-2"},
+		{"L1-3", "L2-2"},
+		{"L1-1", "L2-3", "L3-2"},
+		{"L2-1", "L3-3", "L4-2"},
+		{"L3-1", "L4-3"},
+		{"L4-1"},
+	}
+	if !slices.EqualFunc(result.Moves, wantMoves, slices.Equal) {
+		t.Errorf("moves = %v, want %v", result.Moves, wantMoves)
+	}
+	if result.Turns != len(wantMoves) {
+		t.Errorf("turns = %d, want %d", result.Turns, len(wantMoves))
+	}
+}
+
+func TestSolveNoPath(t *testing.T) {
+	graph := mustParse(t, `1
+##start
+s 0 0
+a 1 0
+b 2 0
+##end
+e 3 0
+s-a
+b-e
+`, parseOptions{})
+	if _, err := (DFSSolver{}).Solve(graph); err == nil {
+		t.Error("solving a map with no path: no error")
+	}
+}
+
+// testSolvers are the solvers with their default settings, by -algo name.
+var testSolvers = map[string]Solver{
+	"dfs":   DFSSolver{},
+	"flow":  FlowSolver{},
+	"dinic": DinicSolver{},
+}
+
+func TestSolversProduceValidMoves(t *testing.T) {
+	for _, name := range exampleMaps {
+		for algo, solver := range testSolvers {
+			t.Run(algo+"/"+name, func(t *testing.T) {
+				graph := readExample(t, name)
+				result, err := solver.Solve(graph)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := validateMoves(graph, result.Moves); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+func TestMaxDetour(t *testing.T) {
+	tests := []struct {
+		name   string
+		detour int
+	}{
+		{"example01.txt", 1},
+		{"example05.txt", 3},
+	}
+	for _, tt := range tests {
+		graph := readExample(t, tt.name)
+		all := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1)
+		pruned := findShortestPaths(graph, graph.StartRoom, "dfs", len(findShortestPath(graph))+tt.detour, 1)
+		if len(pruned) >= len(all) {
+			t.Errorf("%s: a detour of %d keeps %d of %d paths", tt.name, tt.detour, len(pruned), len(all))
+		}
+
+		want, err := DFSSolver{}.Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DFSSolver{MaxDetour: tt.detour}.Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Turns != want.Turns {
+			t.Errorf("%s: %d turns with a detour of %d, want %d", tt.name, got.Turns, tt.detour, want.Turns)
+		}
+	}
+}
+
+// optimalTurns holds the fewest turns known to solve each example map.
+var optimalTurns = map[string]int{
+	"example00.txt": 6,
+	"example01.txt": 8,
+	"example02.txt": 11,
+	"example03.txt": 6,
+	"example04.txt": 6,
+	"example05.txt": 8,
+	"example06.txt": 52,
+	"example07.txt": 502,
+}
+
+// checkOptimal solves the map with solver and fails unless the moves are
+// valid and take at most tolerance turns more than want.
+func checkOptimal(t *testing.T, solver Solver, graph *Graph, want, tolerance int) {
+	t.Helper()
+	result, err := solver.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMoves(graph, result.Moves); err != nil {
+		t.Fatal(err)
+	}
+	if result.Turns != len(result.Moves) {
+		t.Errorf("result reports %d turns for %d turns of moves", result.Turns, len(result.Moves))
+	}
+	if result.Turns < want || result.Turns > want+tolerance {
+		t.Errorf("solved in %d turns, want %d", result.Turns, want)
+	}
+}
+
+func TestOptimalTurns(t *testing.T) {
+	for _, name := range exampleMaps {
+		for algo, solver := range testSolvers {
+			t.Run(algo+"/"+name, func(t *testing.T) {
+				checkOptimal(t, solver, readExample(t, name), optimalTurns[name], 0)
+			})
+		}
+	}
+}
+
+func TestMaxPaths(t *testing.T) {
+	graph := readExample(t, "example01.txt")
+	shortest := findShortestPath(graph)
+	solvers := map[string]func(maxPaths int) Solver{
+		"dfs":   func(maxPaths int) Solver { return DFSSolver{MaxPaths: maxPaths} },
+		"flow":  func(maxPaths int) Solver { return FlowSolver{MaxPaths: maxPaths} },
+		"dinic": func(maxPaths int) Solver { return DinicSolver{MaxPaths: maxPaths} },
+	}
+	for algo, solver := range solvers {
+		all, err := solver(0).Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		one, err := solver(1).Solve(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(one.Paths) != 1 || len(one.Paths[0]) != len(shortest) {
+			t.Errorf("%s: capped to one path, chose %v, want a path as short as %v", algo, one.Paths, shortest)
+		}
+		// Every ant queues for the one path, one turn apart.
+		if want := len(shortest) - 1 + graph.AntCount - 1; one.Turns != want {
+			t.Errorf("%s: %d turns on one path, want %d", algo, one.Turns, want)
+		}
+		if one.Turns <= all.Turns {
+			t.Errorf("%s: %d turns on one path, not more than %d on %d", algo, one.Turns, all.Turns, len(all.Paths))
+		}
+	}
+}
+
+func TestSelectBestGroupTruncates(t *testing.T) {
+	// The group is anchored on its longest path, which the cap drops first.
+	group := [][]string{
+		{"s", "x", "y", "z", "e"},
+		{"s", "a", "e"},
+		{"s", "b", "c", "e"},
+	}
+	got := selectBestGroup([][][]string{group}, 10, 2)
+	want := [][]string{{"s", "a", "e"}, {"s", "b", "c", "e"}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("capped group = %v, want %v", got, want)
+	}
+	if len(group[0]) != 5 {
+		t.Error("capping reordered the group it was given")
+	}
+}
+
+func TestMinimizeMoves(t *testing.T) {
+	graph := mustParse(t, blockingMap, parseOptions{})
+	for _, algo := range []string{"dfs", "flow", "dinic"} {
+		for _, tt := range []struct {
+			minimize     string
+			moves, turns int
+		}{
+			{"turns", 80, 13},
+			{"moves", 60, 22},
+		} {
+			_, results := solveText(t, blockingMap, options{algo: algo, minimize: tt.minimize})
+			_, movesText, _ := strings.Cut(results, "\n\n")
+			turns := strings.Split(strings.TrimSuffix(movesText, "\n"), "\n")
+			moves := make([][]string, len(turns))
+			count := 0
+			for i, turn := range turns {
+				moves[i] = strings.Fields(turn)
+				count += len(moves[i])
+			}
+			if err := validateMoves(graph, moves); err != nil {
+				t.Errorf("%s, -minimize=%s: %v", algo, tt.minimize, err)
+			}
+			if count != tt.moves || len(turns) != tt.turns {
+				t.Errorf("%s, -minimize=%s: %d moves in %d turns, want %d in %d", algo, tt.minimize, count, len(turns), tt.moves, tt.turns)
+			}
+		}
+	}
+}
+
+// recordingHandler keeps the records logged through it.
+type recordingHandler struct {
+	records *[]slog.Record
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	*h.records = append(*h.records, record)
+	return nil
+}
+
+func (h recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func TestSolverLogging(t *testing.T) {
+	graph := readExample(t, "example00.txt")
+	for _, algo := range []string{"dfs", "flow", "dinic"} {
+		var records []slog.Record
+		solver, err := newSolver(options{algo: algo, search: "dfs"}, slog.New(recordingHandler{&records}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := solver.Solve(graph); err != nil {
+			t.Fatal(err)
+		}
+
+		var messages []string
+		for _, record := range records {
+			messages = append(messages, record.Message)
+		}
+		found := slices.Index(messages, "paths found")
+		chosen := slices.Index(messages, "group chosen")
+		if found < 0 || chosen < found {
+			t.Errorf("%s: logged %q, want \"paths found\" then \"group chosen\"", algo, messages)
+			continue
+		}
+		var turns int64
+		records[chosen].Attrs(func(attr slog.Attr) bool {
+			if attr.Key == "turns" {
+				turns = attr.Value.Int64()
+			}
+			return true
+		})
+		if turns != 6 {
+			t.Errorf("%s: group chosen with turns=%d, want 6", algo, turns)
+		}
+	}
+}
+
+func TestPathSearches(t *testing.T) {
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		var dfs [][]string
+		findAllPaths(graph, graph.StartRoom, make(map[string]bool), []string{}, &dfs, 0)
+		bfs := findAllPathsBFS(graph, graph.StartRoom, 0)
+		sortByRooms := func(paths [][]string) { slices.SortFunc(paths, slices.Compare) }
+		sortByRooms(dfs)
+		sortByRooms(bfs)
+		if !slices.EqualFunc(dfs, bfs, slices.Equal) {
+			t.Errorf("%s: depth-first search found %d paths, breadth-first %d", name, len(dfs), len(bfs))
+		}
+
+		for _, search := range pathSearches {
+			checkOptimal(t, DFSSolver{Search: search}, graph, optimalTurns[name], 0)
+		}
+	}
+}
+
+// longChainMap returns a map of rooms rooms in a line, the start at one end and
+// the end at the other.
+func longChainMap(rooms, ants int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\n##start\nr0 0 0\n", ants)
+	for i := 1; i < rooms-1; i++ {
+		fmt.Fprintf(&b, "r%d %d 0\n", i, i)
+	}
+	fmt.Fprintf(&b, "##end\nr%d %d 0\n", rooms-1, rooms-1)
+	for i := 1; i < rooms; i++ {
+		fmt.Fprintf(&b, "r%d-r%d\n", i-1, i)
+	}
+	return b.String()
+}
+
+func TestLongChainBFS(t *testing.T) {
+	const rooms = 50000
+	graph := mustParse(t, longChainMap(rooms, 3), parseOptions{})
+	paths := findAllPathsBFS(graph, graph.StartRoom, 0)
+	if len(paths) != 1 || len(paths[0]) != rooms {
+		t.Fatalf("found %d paths, want one of %d rooms", len(paths), rooms)
+	}
+	checkOptimal(t, DFSSolver{Search: "bfs"}, graph, rooms-1+2, 0)
+}
+
+func TestLongChainDFS(t *testing.T) {
+	const rooms = 50000
+	graph := mustParse(t, longChainMap(rooms, 3), parseOptions{})
+	// A recursive search would need far more stack than this.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	for _, workers := range []int{1, 4} {
+		paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, workers)
+		if len(paths) != 1 || len(paths[0]) != rooms || paths[0][rooms-1] != graph.EndRoom {
+			t.Fatalf("%d workers: found %d paths, want one of %d rooms", workers, len(paths), rooms)
+		}
+		for i, room := range paths[0] {
+			if room != fmt.Sprintf("r%d", i) {
+				t.Fatalf("%d workers: room %d of the path is %s", workers, i, room)
+			}
+		}
+	}
+	checkOptimal(t, DFSSolver{}, graph, rooms-1+2, 0)
+}
+
+func TestDedupePaths(t *testing.T) {
+	graph := mustParse(t, "3\n##start\ns 0 0\na 1 0\nb 1 1\nc 2 1\n##end\ne 3 0\ns-a\na-e\na-b\nb-e\ns-c\nc-e\ns-e\n", parseOptions{})
+	paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1)
+	// s-a-b-e passes through a, so s-a-e can always replace it; the direct
+	// link has no rooms to dominate the others with.
+	want := [][]string{{"s", "e"}, {"s", "a", "e"}, {"s", "c", "e"}}
+	if got := dedupePaths(graph, paths); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("deduplicated %v to %v, want %v", paths, got, want)
+	}
+
+	reduced := false
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		paths := findShortestPaths(graph, graph.StartRoom, "dfs", 0, 1)
+		if len(dedupePaths(graph, paths)) < len(paths) {
+			reduced = true
+		}
+		checkOptimal(t, DFSSolver{Dedupe: true}, graph, optimalTurns[name], 0)
+	}
+	if !reduced {
+		t.Error("deduplication removed no path from any example")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// svgScale is the number of pixels per unit of room coordinates.
+	svgScale = 40
+	// svgMargin keeps the rooms and their labels clear of the image edges.
+	svgMargin = 30
+	// svgRadius is the radius of the circle drawn for each room.
+	svgRadius = 10
+)
+
+// ToSVG draws the graph as an SVG image, placing each room at its
+// coordinates. Rooms are circles labelled with their names, the start room
+// outlined in green and the end room in red. Each tunnel is a line, with an
+// arrow if it leads one way and its weight beside it if it has one. SVG's y
+// axis points down, so rooms with larger y coordinates are drawn lower.
+func (g *Graph) ToSVG(w io.Writer) error {
+	names := make([]string, 0, len(g.Rooms))
+	for name := range g.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	minX, minY, maxX, maxY := 0.0, 0.0, 0.0, 0.0
+	for i, name := range names {
+		room := g.Rooms[name]
+		if i == 0 {
+			minX, minY, maxX, maxY = room.FX, room.FY, room.FX, room.FY
+		}
+		minX, maxX = min(minX, room.FX), max(maxX, room.FX)
+		minY, maxY = min(minY, room.FY), max(maxY, room.FY)
+	}
+	position := func(name string) (float64, float64) {
+		room := g.Rooms[name]
+		return svgMargin + (room.FX-minX)*svgScale, svgMargin + (room.FY-minY)*svgScale
+	}
+	width := formatCoordinate((maxX-minX)*svgScale + 2*svgMargin)
+	height := formatCoordinate((maxY-minY)*svgScale + 2*svgMargin)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\">\n", width, height, width, height)
+	buf.WriteString("\t<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\"/></marker></defs>\n")
+	for _, name := range names {
+		neighbors := append([]string(nil), g.Connections[name]...)
+		sort.Strings(neighbors)
+		for _, neighbor := range neighbors {
+			// Each two-way tunnel is stored in both directions; draw it
+			// once.
+			if !g.lists(name, neighbor) {
+				continue
+			}
+			x1, y1 := position(name)
+			x2, y2 := position(neighbor)
+			// Stop the line at the edge of each circle, so that an arrow
+			// is not hidden under the room it points to.
+			if length := math.Hypot(x2-x1, y2-y1); length > 2*svgRadius {
+				dx, dy := (x2-x1)/length*svgRadius, (y2-y1)/length*svgRadius
+				x1, y1, x2, y2 = x1+dx, y1+dy, x2-dx, y2-dy
+			}
+			fmt.Fprintf(&buf, "\t<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"gray\"",
+				formatCoordinate(x1), formatCoordinate(y1), formatCoordinate(x2), formatCoordinate(y2))
+			if g.OneWay(name, neighbor) {
+				buf.WriteString(" marker-end=\"url(#arrow)\"")
+			}
+			buf.WriteString("/>\n")
+			if weight := g.Weight(name, neighbor); weight != 1 {
+				fmt.Fprintf(&buf, "\t<text x=\"%s\" y=\"%s\" font-size=\"10\" fill=\"gray\">%d</text>\n",
+					formatCoordinate((x1+x2)/2), formatCoordinate((y1+y2)/2), weight)
+			}
+		}
+	}
+	for _, name := range names {
+		room := g.Rooms[name]
+		x, y := position(name)
+		stroke := "black"
+		switch {
+		case room.IsStart:
+			stroke = "green"
+		case room.IsEnd:
+			stroke = "red"
+		}
+		fmt.Fprintf(&buf, "\t<circle cx=\"%s\" cy=\"%s\" r=\"%d\" fill=\"white\" stroke=\"%s\" stroke-width=\"2\"/>\n",
+			formatCoordinate(x), formatCoordinate(y), svgRadius, stroke)
+		fmt.Fprintf(&buf, "\t<text x=\"%s\" y=\"%s\" font-size=\"12\" text-anchor=\"middle\">",
+			formatCoordinate(x), formatCoordinate(y+svgRadius+12))
+		if err := xml.EscapeText(&buf, []byte(name)); err != nil {
+			return err
+		}
+		buf.WriteString("</text>\n")
+	}
+	buf.WriteString("</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
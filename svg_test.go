@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToSVG(t *testing.T) {
+	graph := mustParse(t, "1\n##start\ns 0 0\na&b 2 1\n##end\ne 4 0\ns-a&b:3\na&b->e\n", parseOptions{})
+	var buf bytes.Buffer
+	if err := graph.ToSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+
+	// The output must be well-formed XML, names escaped.
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="220" height="100" viewBox="0 0 220 100">`,
+		`<circle cx="30" cy="30" r="10" fill="white" stroke="green" stroke-width="2"/>`,
+		`<circle cx="110" cy="70" r="10" fill="white" stroke="black" stroke-width="2"/>`,
+		`<circle cx="190" cy="30" r="10" fill="white" stroke="red" stroke-width="2"/>`,
+		`>a&amp;b</text>`,
+		`>3</text>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG lacks %s:\n%s", want, svg)
+		}
+	}
+	if lines := strings.Count(svg, "<line "); lines != 2 {
+		t.Errorf("SVG has %d lines, want 2", lines)
+	}
+	if arrows := strings.Count(svg, `marker-end="url(#arrow)"`); arrows != 1 {
+		t.Errorf("SVG has %d arrows, want 1 for the one-way tunnel", arrows)
+	}
+}
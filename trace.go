@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// roomVisit is an ant entering a room in a given turn.
+type roomVisit struct {
+	Room string
+	Turn int
+}
+
+// antTracer records when each ant entered each room of its path. Pass its
+// record method to writeAntMoves to follow a simulation.
+type antTracer struct {
+	start  string
+	turn   int
+	visits map[int][]roomVisit
+}
+
+// newAntTracer returns a tracer for ants that set out from the start room.
+func newAntTracer(start string) *antTracer {
+	return &antTracer{start: start, visits: make(map[int][]roomVisit)}
+}
+
+// record notes the moves made in the next turn.
+func (t *antTracer) record(moves []antMove) {
+	t.turn++
+	for _, move := range moves {
+		t.visits[move.Ant] = append(t.visits[move.Ant], roomVisit{Room: move.To, Turn: t.turn})
+	}
+}
+
+// trace describes the route of an ant, e.g. "Ant 3: start(t0) -> r1(t1)".
+func (t *antTracer) trace(ant int) string {
+	steps := []string{fmt.Sprintf("%s(t0)", t.start)}
+	for _, visit := range t.visits[ant] {
+		steps = append(steps, fmt.Sprintf("%s(t%d)", visit.Room, visit.Turn))
+	}
+	return fmt.Sprintf("Ant %d: %s", ant, strings.Join(steps, " -> "))
+}
+
+// printAntTrace reports the path assigned to an ant and when it entered each
+// room along it, as recorded by tracer.
+func printAntTrace(w io.Writer, tracer *antTracer, assignment map[int][]string, ant int) {
+	fmt.Fprintf(w, "Ant %d path: %s\n", ant, strings.Join(assignment[ant], " -> "))
+	fmt.Fprintln(w, tracer.trace(ant))
+}
+
+// tunnelUsage counts, for each tunnel keyed "from-to", the ants that crossed
+// it and the turns in which it carried any. The two differ for tunnels wide
+// enough to carry several ants at once. Pass its record method to
+// writeAntMoves to follow a simulation.
+type tunnelUsage struct {
+	ants map[string]int
+	busy map[string]int
+}
+
+// newTunnelUsage returns a tunnelUsage that has seen no moves.
+func newTunnelUsage() tunnelUsage {
+	return tunnelUsage{ants: make(map[string]int), busy: make(map[string]int)}
+}
+
+// record counts the moves made in the next turn.
+func (u tunnelUsage) record(moves []antMove) {
+	used := make(map[string]bool, len(moves))
+	for _, move := range moves {
+		tunnel := move.From + "-" + move.To
+		u.ants[tunnel]++
+		if !used[tunnel] {
+			used[tunnel] = true
+			u.busy[tunnel]++
+		}
+	}
+}
+
+// antsPerPath counts the ants assigned to each of the paths.
+func antsPerPath(paths [][]string, assignment map[int][]string) []int {
+	counts := make([]int, len(paths))
+	for _, assigned := range assignment {
+		for i, path := range paths {
+			if slices.Equal(path, assigned) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// printUsage reports how many ants took each path and how often each tunnel
+// was crossed over a run of the given number of turns.
+func printUsage(w io.Writer, paths [][]string, assignment map[int][]string, usage tunnelUsage, turns int) {
+	fmt.Fprintln(w, "Ants per path:")
+	for i, count := range antsPerPath(paths, assignment) {
+		fmt.Fprintf(w, "Path %d: %d ants\n", i+1, count)
+	}
+
+	tunnels := make([]string, 0, len(usage.ants))
+	for tunnel := range usage.ants {
+		tunnels = append(tunnels, tunnel)
+	}
+	sort.Strings(tunnels)
+	fmt.Fprintln(w, "Tunnel usage:")
+	for _, tunnel := range tunnels {
+		// A wide tunnel carries several ants in a turn, so it is busy for
+		// the turns it carried any, not one turn per ant.
+		fmt.Fprintf(w, "%s: %d ants, busy %d%% of turns\n", tunnel, usage.ants[tunnel], 100*usage.busy[tunnel]/max(turns, 1))
+	}
+}
+
+// roomHeatmap simulates the ants along their assigned paths and returns, for
+// each room, the number of ant-turns spent there: every turn, each ant adds
+// one to the room it is in once the turn's moves are made. Ants waiting in the
+// start room and ants that reached the end room count too.
+func roomHeatmap(graph *Graph, assignment map[int][]string, sim simOptions) (map[string]int, error) {
+	positions := make(map[int]string, len(assignment))
+	for ant := range assignment {
+		positions[ant] = graph.StartRoom
+	}
+	heat := make(map[string]int)
+	_, err := simulateAntMoves(graph, assignment, sim, func(moves []antMove) error {
+		for _, move := range moves {
+			positions[move.Ant] = move.To
+		}
+		for _, room := range positions {
+			heat[room]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return heat, nil
+}
+
+// printHeatmap writes the ant-turns spent in each room, busiest room first.
+func printHeatmap(w io.Writer, heat map[string]int) {
+	rooms := make([]string, 0, len(heat))
+	for room := range heat {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if heat[rooms[i]] != heat[rooms[j]] {
+			return heat[rooms[i]] > heat[rooms[j]]
+		}
+		return rooms[i] < rooms[j]
+	})
+	fmt.Fprintln(w, "Ant-turns per room:")
+	for _, room := range rooms {
+		fmt.Fprintf(w, "%s: %d\n", room, heat[room])
+	}
+}
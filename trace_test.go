@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const chainMap = "1\n##start\ns 0 0\nm 1 0\n##end\ne 2 0\ns-m\nm-e\n"
+
+func TestAntTrace(t *testing.T) {
+	graph := mustParse(t, chainMap, parseOptions{})
+	assignment := map[int][]string{1: {"s", "m", "e"}}
+	tracer := newAntTracer(graph.StartRoom)
+	var buf bytes.Buffer
+	out := &moveWriter{w: bufio.NewWriter(&buf)}
+	if _, err := writeAntMoves(out, graph, assignment, simOptions{}, tracer.record); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tracer.trace(1), "Ant 1: s(t0) -> m(t1) -> e(t2)"; got != want {
+		t.Errorf("trace = %q, want %q", got, want)
+	}
+
+	var report bytes.Buffer
+	printAntTrace(&report, tracer, assignment, 1)
+	want := "Ant 1 path: s -> m -> e\nAnt 1: s(t0) -> m(t1) -> e(t2)\n"
+	if report.String() != want {
+		t.Errorf("printAntTrace wrote %q, want %q", report.String(), want)
+	}
+
+	stdout, _ := solveText(t, chainMap, options{verbose: true})
+	if !strings.Contains(stdout, "\nAnt 1: s(t0) -> m(t1) -> e(t2)\n") {
+		t.Errorf("verbose output has no trace of ant 1:\n%s", stdout)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, name := range exampleMaps {
+		graph := readExample(t, name)
+		paths, err := DFSSolver{}.ChoosePaths(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assignment, err := distributeAnts(paths, graph.AntCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := antsPerPath(paths, assignment)
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		if total != graph.AntCount {
+			t.Errorf("%s: %v ants per path add up to %d, want %d", name, counts, total, graph.AntCount)
+		}
+
+		usage := newTunnelUsage()
+		moves := 0
+		countMoves := func(turn []antMove) { moves += len(turn) }
+		out := &moveWriter{w: bufio.NewWriter(io.Discard)}
+		if _, err := writeAntMoves(out, graph, assignment, simOptions{}, usage.record, countMoves); err != nil {
+			t.Fatal(err)
+		}
+		crossings, left := 0, 0
+		for tunnel, count := range usage.ants {
+			crossings += count
+			if from, _, _ := strings.Cut(tunnel, "-"); from == graph.StartRoom {
+				left += count
+			}
+		}
+		if crossings != moves {
+			t.Errorf("%s: tunnels crossed %d times in %d moves", name, crossings, moves)
+		}
+		if left != graph.AntCount {
+			t.Errorf("%s: %d ants left the start, want %d", name, left, graph.AntCount)
+		}
+	}
+}
+
+func TestRoomHeatmap(t *testing.T) {
+	graph := mustParse(t, "2\n##start\ns 0 0\na 1 0\n##end\ne 2 0\ns-a\na-e\n", parseOptions{})
+	assignment, err := distributeAnts([][]string{{"s", "a", "e"}}, graph.AntCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heat, err := roomHeatmap(graph, assignment, simOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// After turn 1 L2 waits in s and L1 is in a; after turn 2 L2 is in a
+	// and L1 in e; after turn 3 both are in e.
+	want := map[string]int{"s": 1, "a": 2, "e": 3}
+	if !maps.Equal(heat, want) {
+		t.Errorf("heatmap = %v, want %v", heat, want)
+	}
+
+	var buf bytes.Buffer
+	printHeatmap(&buf, heat)
+	if got := buf.String(); got != "Ant-turns per room:\ne: 3\na: 2\ns: 1\n" {
+		t.Errorf("printHeatmap wrote %q", got)
+	}
+}
+
+func TestTraceAntFlag(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "example01.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := readExample(t, "example01.txt")
+	for _, ant := range []int{1, 7} {
+		stdout, results := solveText(t, string(data), options{noEcho: true, traceAnt: ant})
+
+		// The ant's path as the moves show it.
+		rooms := []string{graph.StartRoom}
+		prefix := fmt.Sprintf("L%d-", ant)
+		for _, move := range strings.Fields(results) {
+			if room, ok := strings.CutPrefix(move, prefix); ok {
+				rooms = append(rooms, room)
+			}
+		}
+		if want := fmt.Sprintf("Ant %d path: %s\n", ant, strings.Join(rooms, " -> ")); !strings.Contains(stdout, want) {
+			t.Errorf("-trace-ant %d printed:\n%s\nwant %q", ant, stdout, want)
+		}
+		if strings.Count(stdout, "path: ") != 1 {
+			t.Errorf("-trace-ant %d traced more than one ant:\n%s", ant, stdout)
+		}
+	}
+
+	opts := options{traceAnt: 11, algo: "dfs", search: "dfs", logger: discardLogger}
+	err = solveMap(opts, DFSSolver{}, graph, nil, io.Discard)
+	if err == nil || err.Error() != "cannot trace ant 11: the map has 10 ants" {
+		t.Errorf("tracing a missing ant: err = %v", err)
+	}
+}
+
+func TestWideTunnelUsage(t *testing.T) {
+	const farm = "6\n##start\ns 0 0\n##end\ne 1 0\ns-e*3\n"
+	stdout, results := solveText(t, farm, options{noEcho: true, verbose: true})
+	if results != "L1-e L2-e L3-e\nL4-e L5-e L6-e\n" {
+		t.Fatalf("moves:\n%s\nwant three ants a turn", results)
+	}
+	if !strings.Contains(stdout, "s-e: 6 ants, busy 100% of turns\n") {
+		t.Errorf("-v output:\n%s\nwant s-e busy 100%% of turns", stdout)
+	}
+
+	usage := newTunnelUsage()
+	usage.record([]antMove{{Ant: 1, From: "s", To: "e"}, {Ant: 2, From: "s", To: "e"}, {Ant: 3, From: "s", To: "a"}})
+	usage.record([]antMove{{Ant: 3, From: "a", To: "e"}})
+	for tunnel, want := range map[string][2]int{"s-e": {2, 1}, "s-a": {1, 1}, "a-e": {1, 1}} {
+		if got := [2]int{usage.ants[tunnel], usage.busy[tunnel]}; got != want {
+			t.Errorf("%s: %d ants over %d turns, want %d over %d", tunnel, got[0], got[1], want[0], want[1])
+		}
+	}
+}
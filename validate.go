@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseMove splits a move such as "L3-room" into the ant number and room.
+func parseMove(text string) (int, string, error) {
+	antStr, room, ok := strings.Cut(strings.TrimPrefix(text, "L"), "-")
+	if !ok || !strings.HasPrefix(text, "L") || room == "" {
+		return 0, "", fmt.Errorf("invalid move: %s", text)
+	}
+	ant, err := strconv.Atoi(antStr)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid move: %s", text)
+	}
+	return ant, room, nil
+}
+
+// moveValidator checks turns of moves against the rules one turn at a time:
+// each ant moves at most once per turn, only through an existing tunnel,
+// never through the same tunnel as another ant in that turn, and rooms other
+// than the start and end never hold more ants than their capacity.
+type moveValidator struct {
+	graph     *Graph
+	positions map[int]string // room of each ant; ants not listed are at the start
+	occupancy map[string]int
+	turn      int
+}
+
+// newMoveValidator returns a validator with every ant in the start room.
+func newMoveValidator(graph *Graph) *moveValidator {
+	return &moveValidator{
+		graph:     graph,
+		positions: make(map[int]string),
+		occupancy: make(map[string]int),
+	}
+}
+
+// clone returns an independent copy of the validator's state.
+func (v *moveValidator) clone() *moveValidator {
+	clone := &moveValidator{
+		graph:     v.graph,
+		positions: make(map[int]string, len(v.positions)),
+		occupancy: make(map[string]int, len(v.occupancy)),
+		turn:      v.turn,
+	}
+	for ant, room := range v.positions {
+		clone.positions[ant] = room
+	}
+	for room, count := range v.occupancy {
+		clone.occupancy[room] = count
+	}
+	return clone
+}
+
+// position returns the room an ant is in.
+func (v *moveValidator) position(ant int) string {
+	if room, ok := v.positions[ant]; ok {
+		return room
+	}
+	return v.graph.StartRoom
+}
+
+// limited reports whether a room has a limited capacity, which every room but
+// the start and end has.
+func (v *moveValidator) limited(room string) bool {
+	return !v.graph.Unlimited(room)
+}
+
+// waiting returns how many ants are in the start room.
+func (v *moveValidator) waiting() int {
+	count := v.graph.AntCount
+	for _, room := range v.positions {
+		if room != v.graph.StartRoom {
+			count--
+		}
+	}
+	return count
+}
+
+// step checks and applies the next turn of moves. Errors name the turn.
+func (v *moveValidator) step(moves []string) error {
+	v.turn++
+	moved := make(map[int]bool)
+	tunnelsUsed := make(map[string]int)
+	var entered []string
+	for _, text := range moves {
+		ant, room, err := parseMove(text)
+		if err != nil {
+			return fmt.Errorf("turn %d: %w", v.turn, err)
+		}
+		if ant < 1 || ant > v.graph.AntCount {
+			return fmt.Errorf("turn %d: unknown ant in move %s", v.turn, text)
+		}
+		if moved[ant] {
+			return fmt.Errorf("turn %d: ant %d moves twice", v.turn, ant)
+		}
+		from := v.position(ant)
+		if from == v.graph.EndRoom {
+			return fmt.Errorf("turn %d: ant %d has already reached the end", v.turn, ant)
+		}
+		if !v.graph.Connected(from, room) {
+			return fmt.Errorf("turn %d: no tunnel from %s to %s for ant %d", v.turn, from, room, ant)
+		}
+		if width := v.graph.Width(from, room); tunnelsUsed[from+"->"+room] >= width {
+			return fmt.Errorf("turn %d: tunnel %s-%s used by more ants than its width of %d", v.turn, from, room, width)
+		}
+		moved[ant] = true
+		tunnelsUsed[from+"->"+room]++
+		v.positions[ant] = room
+		// The start and end rooms hold any number of ants, so only the
+		// rooms between them are counted.
+		if v.limited(from) {
+			if v.occupancy[from]--; v.occupancy[from] == 0 {
+				delete(v.occupancy, from)
+			}
+		}
+		if v.limited(room) {
+			v.occupancy[room]++
+			entered = append(entered, room)
+		}
+	}
+
+	// Rooms are checked once every ant has moved, so an ant may enter a
+	// room in the same turn another ant leaves it. Only a room an ant
+	// entered can have filled up.
+	for _, room := range entered {
+		if count, capacity := v.occupancy[room], v.graph.Rooms[room].Capacity; count > capacity {
+			return fmt.Errorf("turn %d: room %s holds %d ants but fits %d", v.turn, room, count, capacity)
+		}
+	}
+	return nil
+}
+
+// finish checks that every ant has reached the end room.
+func (v *moveValidator) finish() error {
+	for ant := 1; ant <= v.graph.AntCount; ant++ {
+		if room := v.position(ant); room != v.graph.EndRoom {
+			return fmt.Errorf("after turn %d: ant %d is still in %s", v.turn, ant, room)
+		}
+	}
+	return nil
+}
+
+// rooms returns the ants in each room other than the start, with each room's
+// ants in ascending order.
+func (v *moveValidator) rooms() map[string][]int {
+	rooms := make(map[string][]int)
+	for ant, room := range v.positions {
+		if room != v.graph.StartRoom {
+			rooms[room] = append(rooms[room], ant)
+		}
+	}
+	for _, ants := range rooms {
+		sort.Ints(ants)
+	}
+	return rooms
+}
+
+// validateMoves checks a complete solution: every turn must follow the rules
+// and every ant must end up in the end room.
+func validateMoves(graph *Graph, turns [][]string) error {
+	validator := newMoveValidator(graph)
+	for _, moves := range turns {
+		if err := validator.step(moves); err != nil {
+			return err
+		}
+	}
+	return validator.finish()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidatorOccupancy(t *testing.T) {
+	const rooms = 1000
+	graph := mustParse(t, longChainMap(rooms, 2), parseOptions{})
+	validator := newMoveValidator(graph)
+	for turn := 1; turn <= rooms; turn++ {
+		var moves []string
+		for ant := 1; ant <= 2; ant++ {
+			if step := turn - ant + 1; step >= 1 && step < rooms {
+				moves = append(moves, fmt.Sprintf("L%d-r%d", ant, step))
+			}
+		}
+		if err := validator.step(moves); err != nil {
+			t.Fatal(err)
+		}
+		// Only the rooms the ants are in are counted, however many they
+		// have passed through.
+		if len(validator.occupancy) > 2 {
+			t.Fatalf("after turn %d: occupancy tracks %d rooms for 2 ants", turn, len(validator.occupancy))
+		}
+	}
+	if err := validator.finish(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidatorRoomCapacity(t *testing.T) {
+	graph := mustParse(t, "3\n##start\ns 0 0\na 1 0\nb 1 1\nc 2 0\n##end\ne 3 0\ns-a\ns-b\na-c\nb-c\nc-e\n", parseOptions{})
+	tests := []struct {
+		name  string
+		turns [][]string
+		want  string
+	}{
+		// Ant 1 leaves c in the same turn ant 2 enters it.
+		{"follow", [][]string{{"L1-a", "L2-b"}, {"L1-c"}, {"L1-e", "L2-c"}, {"L2-e", "L3-a"}, {"L3-c"}, {"L3-e"}}, ""},
+		{"crowd", [][]string{{"L1-a", "L2-b"}, {"L1-c", "L2-c"}}, "turn 2: room c holds 2 ants but fits 1"},
+		// A room stays full while an ant waits in it.
+		{"wait", [][]string{{"L1-a", "L2-b"}, {"L1-c"}, {"L2-c"}}, "turn 3: room c holds 2 ants but fits 1"},
+	}
+	for _, tt := range tests {
+		err := validateMoves(graph, tt.turns)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.want != "" && (err == nil || err.Error() != tt.want) {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// readMoves reads a solution of one turn per line, such as "L1-a L2-b".
+// Blank lines and lines starting with '#' are skipped. If the solution is
+// preceded by the map it solves, as in the program's own output, everything
+// up to the first blank line is skipped as well.
+func readMoves(r io.Reader) ([][]string, error) {
+	var turns [][]string
+	scanner := newLineScanner(r)
+	inMap := false
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			inMap = false
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// No room name starts with 'L', so a first line that doesn't is part
+		// of an echoed map.
+		if len(turns) == 0 && !strings.HasPrefix(line, "L") {
+			inMap = true
+		}
+		if inMap {
+			continue
+		}
+		turns = append(turns, strings.Fields(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, scanError(err, lines)
+	}
+	return turns, nil
+}
+
+// ANSI escape codes used to color moves.
+const (
+	ansiReset = "\x1b[0m"
+	ansiGreen = "\x1b[32m"
+	ansiRed   = "\x1b[31m"
+)
+
+// antColors are cycled through to tell the ants apart: yellow, blue, magenta
+// and cyan.
+var antColors = []string{"\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m"}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorMove wraps a move in ANSI color codes: green for an ant leaving the
+// start, red for an ant reaching the end, and the ant's own color otherwise.
+// The validator must not yet have played the move.
+func colorMove(validator *moveValidator, move string) string {
+	ant, room, err := parseMove(move)
+	if err != nil {
+		return move
+	}
+	color := antColors[ant%len(antColors)]
+	if validator.position(ant) == validator.graph.StartRoom {
+		color = ansiGreen
+	} else if room == validator.graph.EndRoom {
+		color = ansiRed
+	}
+	return color + move + ansiReset
+}
+
+// visualizeAntMovements plays turns of moves over the graph, checking each
+// turn as it goes and printing the moves followed by the number of ants still
+// queued at the start and the ants in every other room. With color set, the
+// moves are colored with colorMove.
+// It stops at the first illegal move, returning an error naming its turn.
+func visualizeAntMovements(w io.Writer, graph *Graph, turns [][]string, color bool) error {
+	out := bufio.NewWriter(w)
+	validator := newMoveValidator(graph)
+	for i, moves := range turns {
+		shown := moves
+		if color {
+			shown = make([]string, len(moves))
+			for j, move := range moves {
+				shown[j] = colorMove(validator, move)
+			}
+		}
+		if err := validator.step(moves); err != nil {
+			out.Flush()
+			return err
+		}
+		fmt.Fprintf(out, "Turn %d: %s\n", i+1, strings.Join(shown, " "))
+		if waiting := validator.waiting(); waiting > 0 {
+			fmt.Fprintf(out, "  %s: %d waiting\n", graph.StartRoom, waiting)
+		}
+
+		rooms := validator.rooms()
+		names := make([]string, 0, len(rooms))
+		for name := range rooms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ants := make([]string, len(rooms[name]))
+			for j, ant := range rooms[name] {
+				ants[j] = fmt.Sprintf("L%d", ant)
+			}
+			fmt.Fprintf(out, "  %s: %s\n", name, strings.Join(ants, " "))
+		}
+	}
+	if err := validator.finish(); err != nil {
+		out.Flush()
+		return err
+	}
+	fmt.Fprintf(out, "All %d ants reached %s in %d turns.\n", graph.AntCount, graph.EndRoom, len(turns))
+	return out.Flush()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "map.txt")
+	if err := os.WriteFile(mapFile, []byte("2\n##start\ns 0 0\nm 1 0\n##end\ne 2 0\ns-m\nm-e\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	replay := func(moves string) (string, error) {
+		t.Helper()
+		movesFile := filepath.Join(dir, "moves.txt")
+		if err := os.WriteFile(movesFile, []byte(moves), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		opts, err := parseArgs([]string{"-replay", movesFile, mapFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return captureStdout(t, func() error { return run(opts) })
+	}
+
+	stdout, err := replay("L1-m\nL1-e L2-m\nL2-e\n# turns: 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Turn 1: L1-m
+  s: 1 waiting
+  m: L1
+Turn 2: L1-e L2-m
+  e: L1
+  m: L2
+Turn 3: L2-e
+  e: L1 L2
+All 2 ants reached e in 3 turns.
+`
+	if stdout != want {
+		t.Errorf("replay printed:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	// The second turn moves L2 into m while L1 is still there.
+	stdout, err = replay("L1-m\nL2-m\nL1-e L2-e\n")
+	if err == nil || !strings.HasPrefix(err.Error(), "turn 2: ") {
+		t.Errorf("illegal move: err = %v, want it to name turn 2", err)
+	}
+	if !strings.HasPrefix(stdout, "Turn 1: L1-m\n") || strings.Contains(stdout, "Turn 2") {
+		t.Errorf("replay should stop before turn 2, printed:\n%s", stdout)
+	}
+}
+
+func TestVisualizeSinglePath(t *testing.T) {
+	// Three tunnels for the first ant, then one more turn for each of the
+	// four ants queued behind it.
+	graph := mustParse(t, "5\n##start\ns 0 0\na 1 0\nb 2 0\n##end\ne 3 0\ns-a\na-b\nb-e\n", parseOptions{})
+	result, err := DFSSolver{}.Solve(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Turns != 7 {
+		t.Errorf("solved in %d turns, want 7", result.Turns)
+	}
+
+	var buf bytes.Buffer
+	if err := visualizeAntMovements(&buf, graph, result.Moves, false); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasSuffix(out, "All 5 ants reached e in 7 turns.\n") {
+		t.Errorf("visualizer did not finish in 7 turns:\n%s", out)
+	}
+	// One ant leaves the start each turn.
+	for i, block := range strings.Split(out, "Turn ")[1:] {
+		waiting := ""
+		if i < 4 {
+			waiting = fmt.Sprintf("\n  s: %d waiting\n", 4-i)
+		}
+		if got := strings.Contains(block, "waiting"); got != (waiting != "") || !strings.Contains(block, waiting) {
+			t.Errorf("turn %d should leave %d ants waiting:\nTurn %s", i+1, max(4-i, 0), block)
+		}
+	}
+}
+
+func TestVisualizeColor(t *testing.T) {
+	const farm = "2\n##start\ns 0 0\na 1 0\nb 2 0\n##end\ne 3 0\ns-a\na-b\nb-e\n"
+	graph := mustParse(t, farm, parseOptions{})
+	turns := [][]string{{"L1-a"}, {"L1-b", "L2-a"}, {"L1-e", "L2-b"}, {"L2-e"}}
+
+	var plain bytes.Buffer
+	if err := visualizeAntMovements(&plain, graph, turns, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("output without color holds escape codes:\n%q", plain.String())
+	}
+	if !strings.Contains(plain.String(), "Turn 2: L1-b L2-a\n") {
+		t.Errorf("plain output lacks the moves of turn 2:\n%s", plain.String())
+	}
+
+	var colored bytes.Buffer
+	if err := visualizeAntMovements(&colored, graph, turns, true); err != nil {
+		t.Fatal(err)
+	}
+	// Leaving the start is green, reaching the end red, and other moves
+	// take the color of the ant.
+	want := []string{
+		"Turn 1: " + ansiGreen + "L1-a" + ansiReset + "\n",
+		"Turn 2: " + antColors[1] + "L1-b" + ansiReset + " " + ansiGreen + "L2-a" + ansiReset + "\n",
+		"Turn 3: " + ansiRed + "L1-e" + ansiReset + " " + antColors[2] + "L2-b" + ansiReset + "\n",
+		"Turn 4: " + ansiRed + "L2-e" + ansiReset + "\n",
+	}
+	for _, line := range want {
+		if !strings.Contains(colored.String(), line) {
+			t.Errorf("colored output lacks %q:\n%q", line, colored.String())
+		}
+	}
+	// Only the moves are colored.
+	if stripped := strings.NewReplacer(ansiGreen, "", ansiRed, "", ansiReset, "", antColors[1], "", antColors[2], "").Replace(colored.String()); stripped != plain.String() {
+		t.Errorf("colored output without its escape codes:\n%s\nwant:\n%s", stripped, plain.String())
+	}
+
+	// -color does nothing when stdout is not a terminal.
+	dir := t.TempDir()
+	mapFile, movesFile := filepath.Join(dir, "map.txt"), filepath.Join(dir, "moves.txt")
+	if err := os.WriteFile(mapFile, []byte(farm), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(movesFile, []byte("L1-a\nL1-b L2-a\nL1-e L2-b\nL2-e\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := parseArgs([]string{"-color", "-replay", movesFile, mapFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := captureStdout(t, func() error { return run(opts) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != plain.String() {
+		t.Errorf("-color to a pipe printed:\n%q\nwant:\n%q", stdout, plain.String())
+	}
+}